@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/getmilly/grok/lifecycle"
+	"github.com/getmilly/grok/logging"
+	"github.com/getmilly/grok/models"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sarulabs/di"
+)
+
+//MetricsRegistryDef is the DI name of the prometheus.Registerer used by the server.
+const MetricsRegistryDef = "metrics-registry"
+
+func (server *Server) configureMetricsRegistry() {
+	if server.Settings.MetricsRegisterer == nil {
+		server.Settings.MetricsRegisterer = prometheus.DefaultRegisterer
+	}
+
+	if server.Settings.MetricsGatherer == nil {
+		server.Settings.MetricsGatherer = prometheus.DefaultGatherer
+
+		if gatherer, ok := server.Settings.MetricsRegisterer.(prometheus.Gatherer); ok {
+			server.Settings.MetricsGatherer = gatherer
+		}
+	}
+
+	if server.Settings.Authorization.Registerer == nil {
+		server.Settings.Authorization.Registerer = server.Settings.MetricsRegisterer
+	}
+
+	err := server.DIBuilder.Add(di.Def{
+		Name:  MetricsRegistryDef,
+		Scope: di.App,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return server.Settings.MetricsRegisterer, nil
+		},
+	})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//registerAdminRoutes adds /metrics, /log/level and /healthz/* to the router,
+//or to a separate admin engine when Settings.AdminHost is set.
+//PUT /log/level is only served by the admin engine, never on the public listener.
+func (server *Server) registerAdminRoutes() {
+	router := server.router
+
+	if server.Settings.AdminHost != "" {
+		server.admin = gin.New()
+		server.admin.Use(gin.Recovery())
+		router = server.admin.Group("")
+	}
+
+	router.GET("/metrics", server.scrapeAuth(), server.metrics())
+	router.GET("/log/level", server.scrapeAuth(), server.logLevel())
+
+	if server.admin != nil {
+		router.PUT("/log/level", server.scrapeAuth(), server.setLogLevel())
+	}
+
+	router.GET("/healthz/liveness", server.liveness())
+	router.GET("/healthz/readiness", server.readiness())
+	router.GET("/healthz/startup", server.startup())
+}
+
+//AdminAddr returns the address the admin server listens on, empty without Settings.AdminHost.
+func (server *Server) AdminAddr() string {
+	if server.adminListener == nil {
+		return server.Settings.AdminHost
+	}
+
+	return server.adminListener.Addr().String()
+}
+
+func (server *Server) startAdmin(ctx context.Context) error {
+	if server.admin == nil {
+		return nil
+	}
+
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", server.Settings.AdminHost)
+
+	if err != nil {
+		logging.LogWith(err).Error("admin startup error")
+		return err
+	}
+
+	srv := &http.Server{Handler: server.admin}
+
+	server.adminListener = listener
+	server.Lifecycle.Register(lifecycle.StageServers, "admin server", srv.Shutdown)
+
+	go func() {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logging.LogWith(err).Error("admin serve error")
+			server.Lifecycle.Fail(err)
+		}
+	}()
+
+	return nil
+}
+
+//scrapeAuth protects /metrics and /log/level with basic auth or a bearer token when configured.
+func (server *Server) scrapeAuth() gin.HandlerFunc {
+	username := server.Settings.MetricsUsername
+	password := server.Settings.MetricsPassword
+	token := server.Settings.MetricsToken
+
+	return func(c *gin.Context) {
+		if password == "" && token == "" {
+			c.Next()
+			return
+		}
+
+		if password != "" {
+			if user, pass, ok := c.Request.BasicAuth(); ok && equal(user, username) && equal(pass, password) {
+				c.Next()
+				return
+			}
+		}
+
+		if token != "" {
+			if bearer, err := bearerToken(c.Request); err == nil && equal(bearer, token) {
+				c.Next()
+				return
+			}
+		}
+
+		var challenges []string
+
+		if password != "" {
+			challenges = append(challenges, `Basic realm="metrics"`)
+		}
+
+		if token != "" {
+			challenges = append(challenges, `Bearer realm="metrics"`)
+		}
+
+		c.Header("WWW-Authenticate", strings.Join(challenges, ", "))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.Error{
+			Code:           CodeTokenMissing,
+			Message:        "Metrics scraping requires credentials",
+			HTTPStatusCode: http.StatusUnauthorized,
+		})
+	}
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
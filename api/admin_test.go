@@ -0,0 +1,168 @@
+package api_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sarulabs/di"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+	"github.com/getmilly/grok/logging"
+)
+
+func TestServer_AdminPortServesProtectedMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	server := api.ConfigureServer(func() *api.Settings {
+		return &api.Settings{
+			Host:              "127.0.0.1:0",
+			AdminHost:         "127.0.0.1:0",
+			MetricsToken:      "scrape-token",
+			MetricsRegisterer: registry,
+		}
+	}, api.DefaultHealthChecks())
+
+	err := server.AddController(di.Def{
+		Name:  "ping-controller",
+		Scope: di.App,
+		Build: func(ctn di.Container) (interface{}, error) {
+			ctn.Get(api.MetricsRegistryDef).(prometheus.Registerer).MustRegister(prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "ping_controller_total",
+				Help: "Registered by a controller.",
+			}))
+
+			return &pingController{}, nil
+		},
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, server.Start(ctx))
+	defer server.Stop(ctx)
+
+	get(t, "http://"+server.Addr()+"/ping")
+
+	status, _ := get(t, "http://"+server.Addr()+"/metrics")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = get(t, "http://"+server.AdminAddr()+"/healthz/liveness")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = get(t, "http://"+server.AdminAddr()+"/metrics")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+server.AdminAddr()+"/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-token")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "ping_controller_total")
+	assert.Contains(t, string(body), `grok_http_requests_total{method="GET",route="/ping",status="2xx"} 1`)
+}
+
+func TestServer_ChangesLogLevelLive(t *testing.T) {
+	logger := logging.NewLogger()
+	logger.SetOutput(ioutil.Discard)
+	defer logging.SetDefault(logging.Default())
+
+	server := api.ConfigureServer(func() *api.Settings {
+		return &api.Settings{
+			Host:              "127.0.0.1:0",
+			AdminHost:         "127.0.0.1:0",
+			LogLevel:          "info",
+			MetricsToken:      "admin-token",
+			MetricsRegisterer: prometheus.NewRegistry(),
+			Logger:            logger,
+		}
+	}, api.DefaultHealthChecks())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, server.Start(ctx))
+	defer server.Stop(ctx)
+
+	put := func(addr, body string) int {
+		req, _ := http.NewRequest(http.MethodPut, "http://"+addr+"/log/level", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer admin-token")
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	status, _ := get(t, "http://"+server.AdminAddr()+"/log/level")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	assert.Equal(t, http.StatusOK, put(server.AdminAddr(), `{"level":"trace"}`))
+	assert.Equal(t, logging.TraceLevel, logger.Level())
+
+	assert.Equal(t, http.StatusBadRequest, put(server.AdminAddr(), `{"level":"verbose"}`))
+	assert.Equal(t, logging.TraceLevel, logger.Level())
+}
+
+func TestServer_DoesNotChangeLogLevelOnPublicListener(t *testing.T) {
+	logger := logging.NewLogger()
+	logger.SetOutput(ioutil.Discard)
+	defer logging.SetDefault(logging.Default())
+
+	server := api.ConfigureServer(func() *api.Settings {
+		return &api.Settings{
+			Host:     "127.0.0.1:0",
+			LogLevel: "info",
+			Logger:   logger,
+		}
+	}, api.DefaultHealthChecks())
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/log/level", strings.NewReader(`{"level":"trace"}`))
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.NotEqual(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, logging.InfoLevel, logger.Level())
+}
+
+func TestServer_ServesLogLevelWithScrapeCredentialsWhenAuthorizing(t *testing.T) {
+	ts := authorizedServer(t, &api.Settings{
+		MetricsToken:      "scrape-token",
+		MetricsRegisterer: prometheus.NewRegistry(),
+	}, di.Def{
+		Name:  "ping-controller",
+		Scope: di.App,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return &pingController{}, nil
+		},
+	})
+	defer ts.Close()
+
+	status, _ := bearerGet(t, ts.URL+"/log/level", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, body := bearerGet(t, ts.URL+"/log/level", "scrape-token")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"level"`)
+
+	status, _ = bearerGet(t, ts.URL+"/ping", "scrape-token")
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
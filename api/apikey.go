@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getmilly/grok/models"
+	"github.com/joho/godotenv"
+)
+
+//AuthModeAPIKey validates API keys from an env file store.
+const AuthModeAPIKey = "apikey"
+
+var (
+	//ErrAPIKeyNotFound is returned when a key isn't in the store.
+	ErrAPIKeyNotFound = models.ErrAPIKeyNotFound
+	//ErrAPIKeyMissing is returned when the request has no API key.
+	ErrAPIKeyMissing = errors.New("API key missing")
+	//ErrAPIKeyRevoked is returned when a key is disabled or expired.
+	ErrAPIKeyRevoked = errors.New("API key revoked")
+)
+
+//APIKey is a machine client credential stored hashed at rest.
+//It's declared in models so stores, e.g. mongodb.APIKeyStore, don't depend on api.
+type APIKey = models.APIKey
+
+//APIKeyStore looks up API keys by their hash.
+type APIKeyStore interface {
+	//FindByHash returns ErrAPIKeyNotFound for unknown hashes, ctx is the request context.
+	FindByHash(ctx context.Context, hash string) (*APIKey, error)
+}
+
+//APIKeyAuth authenticates requests carrying an API key.
+type APIKeyAuth struct {
+	Store  APIKeyStore
+	Header string
+	Query  string
+}
+
+//MemoryAPIKeyStore keeps API keys in memory.
+type MemoryAPIKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+//HashAPIKey returns the hash used to store a key at rest.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+//NewAPIKeyAuthService creates an auth service reading keys from `X-API-Key` header or `api_key` query.
+func NewAPIKeyAuthService(store APIKeyStore) *APIKeyAuth {
+	return &APIKeyAuth{
+		Store:  store,
+		Header: "X-API-Key",
+		Query:  "api_key",
+	}
+}
+
+//Authorize implements AuthService.
+func (service *APIKeyAuth) Authorize(req *http.Request) (Claims, error) {
+	key := service.extract(req)
+
+	if key == "" {
+		return nil, ErrAPIKeyMissing
+	}
+
+	apiKey, err := service.Store.FindByHash(req.Context(), HashAPIKey(key))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if apiKey.Disabled || (!apiKey.ExpiresAt.IsZero() && time.Now().After(apiKey.ExpiresAt)) {
+		return nil, ErrAPIKeyRevoked
+	}
+
+	claims := Claims{
+		"sub":         apiKey.ID,
+		"scope":       strings.Join(apiKey.Scopes, " "),
+		"auth_method": AuthModeAPIKey,
+	}
+
+	if !apiKey.ExpiresAt.IsZero() {
+		claims["exp"] = float64(apiKey.ExpiresAt.Unix())
+	}
+
+	return claims, nil
+}
+
+func (service *APIKeyAuth) extract(req *http.Request) string {
+	if service.Header != "" {
+		if key := req.Header.Get(service.Header); key != "" {
+			return key
+		}
+	}
+
+	if service.Query != "" {
+		return req.URL.Query().Get(service.Query)
+	}
+
+	return ""
+}
+
+//NewMemoryAPIKeyStore creates an in memory store with the given keys.
+func NewMemoryAPIKeyStore(keys ...APIKey) *MemoryAPIKeyStore {
+	store := &MemoryAPIKeyStore{keys: make(map[string]APIKey)}
+
+	for _, key := range keys {
+		store.Add(key)
+	}
+
+	return store
+}
+
+//NewEnvFileAPIKeyStore loads keys from a dotenv file.
+//Each line is `<id>=<sha256 hex hash>:<scope>,<scope>`.
+func NewEnvFileAPIKeyStore(path string) (*MemoryAPIKeyStore, error) {
+	entries, err := godotenv.Read(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	store := NewMemoryAPIKeyStore()
+
+	for id, value := range entries {
+		parts := strings.SplitN(value, ":", 2)
+
+		if parts[0] == "" {
+			return nil, fmt.Errorf("API key `%s` has no hash", id)
+		}
+
+		key := APIKey{ID: id, Hash: strings.ToLower(parts[0])}
+
+		if len(parts) > 1 && parts[1] != "" {
+			key.Scopes = strings.Split(parts[1], ",")
+		}
+
+		store.Add(key)
+	}
+
+	return store, nil
+}
+
+//Add stores a key, replacing any key with the same hash.
+func (store *MemoryAPIKeyStore) Add(key APIKey) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.keys[key.Hash] = key
+}
+
+//FindByHash implements APIKeyStore.
+func (store *MemoryAPIKeyStore) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	key, ok := store.keys[hash]
+
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,141 @@
+package api_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sarulabs/di"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+)
+
+func apiKeysFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "apikeys")
+	assert.NoError(t, err)
+
+	path := filepath.Join(dir, ".apikeys")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	return path
+}
+
+func TestAPIKeyAuth_HeaderAndQuery(t *testing.T) {
+	router := authRouter(api.NewAPIKeyAuthService(api.NewMemoryAPIKeyStore(api.APIKey{
+		ID:   "batch",
+		Hash: api.HashAPIKey("key"),
+	})))
+
+	header := httptest.NewRequest(http.MethodGet, "/", nil)
+	header.Header.Set("X-API-Key", "key")
+
+	w, _ := serve(router, header)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "batch", w.Body.String())
+
+	w, _ = serve(router, httptest.NewRequest(http.MethodGet, "/?api_key=key", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "batch", w.Body.String())
+
+	w, body := serve(router, httptest.NewRequest(http.MethodGet, "/?api_key=unknown", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(t, body.Code)
+}
+
+func TestAPIKeyAuth_RejectsRevokedKeys(t *testing.T) {
+	service := api.NewAPIKeyAuthService(api.NewMemoryAPIKeyStore(
+		api.APIKey{ID: "disabled", Hash: api.HashAPIKey("disabled"), Disabled: true},
+		api.APIKey{ID: "expired", Hash: api.HashAPIKey("expired"), ExpiresAt: time.Now().Add(-time.Minute)},
+	))
+
+	_, err := service.Authorize(httptest.NewRequest(http.MethodGet, "/?api_key=disabled", nil))
+	assert.Equal(t, api.ErrAPIKeyRevoked, err)
+
+	_, err = service.Authorize(httptest.NewRequest(http.MethodGet, "/?api_key=expired", nil))
+	assert.Equal(t, api.ErrAPIKeyRevoked, err)
+
+	_, err = service.Authorize(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, api.ErrAPIKeyMissing, err)
+}
+
+type contextAPIKeyStore struct {
+	ctx context.Context
+}
+
+func (store *contextAPIKeyStore) FindByHash(ctx context.Context, hash string) (*api.APIKey, error) {
+	store.ctx = ctx
+	return nil, ctx.Err()
+}
+
+func TestAPIKeyAuth_PassesRequestContextToStore(t *testing.T) {
+	store := &contextAPIKeyStore{}
+	service := api.NewAPIKeyAuthService(store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/?api_key=key", nil).WithContext(ctx)
+
+	_, err := service.Authorize(req)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, ctx, store.ctx)
+}
+
+func TestEnvFileAPIKeyStore(t *testing.T) {
+	path := apiKeysFile(t, "batch="+api.HashAPIKey("key")+":orders:read,orders:write\nreport="+api.HashAPIKey("other")+"\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	store, err := api.NewEnvFileAPIKeyStore(path)
+	assert.NoError(t, err)
+
+	key, err := store.FindByHash(context.Background(), api.HashAPIKey("key"))
+	assert.NoError(t, err)
+	assert.Equal(t, "batch", key.ID)
+	assert.Equal(t, []string{"orders:read", "orders:write"}, key.Scopes)
+
+	key, err = store.FindByHash(context.Background(), api.HashAPIKey("other"))
+	assert.NoError(t, err)
+	assert.Empty(t, key.Scopes)
+
+	_, err = store.FindByHash(context.Background(), api.HashAPIKey("unknown"))
+	assert.Equal(t, api.ErrAPIKeyNotFound, err)
+
+	invalid := apiKeysFile(t, "batch=:orders:read\n")
+	defer os.RemoveAll(filepath.Dir(invalid))
+
+	_, err = api.NewEnvFileAPIKeyStore(invalid)
+	assert.Error(t, err)
+}
+
+func TestServer_AuthenticatesAPIKeysFromFile(t *testing.T) {
+	path := apiKeysFile(t, "batch="+api.HashAPIKey("key")+":orders:read\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	settings := &api.Settings{}
+	settings.Authorization.Modes = []string{api.AuthModeAPIKey}
+	settings.Authorization.APIKeysFile = path
+
+	ts := authorizedServer(t, settings, di.Def{
+		Name:  "catalog-controller",
+		Scope: di.App,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return &catalogController{}, nil
+		},
+	})
+	defer ts.Close()
+
+	status, _ := bearerGet(t, ts.URL+"/orders?api_key=key", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = bearerGet(t, ts.URL+"/orders", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
@@ -3,8 +3,8 @@ package api
 import (
 	"errors"
 	"net/http"
-	"strings"
 
+	"github.com/getmilly/grok/logging"
 	"github.com/gin-gonic/gin"
 
 	auth0 "github.com/auth0-community/go-auth0"
@@ -17,9 +17,11 @@ type AuthService interface {
 }
 
 type auth struct {
-	JwksURI  string
-	Issuer   string
-	Audience []string
+	JwksURI   string
+	Issuer    string
+	Audience  []string
+	Keys      *JWKSCache
+	validator *auth0.JWTValidator
 }
 
 //Claims wraps user data
@@ -43,43 +45,51 @@ func (claims Claims) GetKey(key string) (interface{}, error) {
 
 //NewAuthService creates a new pointer to an auth handler.
 func NewAuthService(jwks, issuer string, audience []string) AuthService {
+	return NewCachedAuthService(NewJWKSCache(jwks, DefaultKeyCacheOptions()), issuer, audience)
+}
+
+//NewCachedAuthService creates an auth handler that validates tokens against a shared key cache.
+func NewCachedAuthService(keys *JWKSCache, issuer string, audience []string) AuthService {
+	configuration := auth0.NewConfiguration(keys, audience, issuer, jose.RS256)
+
 	return &auth{
-		JwksURI:  jwks,
-		Issuer:   issuer,
-		Audience: audience,
+		JwksURI:   keys.uri,
+		Issuer:    issuer,
+		Audience:  audience,
+		Keys:      keys,
+		validator: auth0.NewValidator(configuration, nil),
 	}
 }
 
 func (service auth) Authorize(req *http.Request) (Claims, error) {
-	client := auth0.NewJWKClient(auth0.JWKClientOptions{URI: service.JwksURI}, nil)
-	configuration := auth0.NewConfiguration(client, service.Audience, service.Issuer, jose.RS256)
-	validator := auth0.NewValidator(configuration, nil)
-
-	jwt, err := validator.ValidateRequest(req)
+	jwt, err := service.validator.ValidateRequest(req)
 
 	if err != nil {
 		return nil, err
 	}
 
 	var claims map[string]interface{}
-	validator.Claims(req, jwt, &claims)
+	service.validator.Claims(req, jwt, &claims)
 
 	return claims, nil
 }
 
 //Authentication authenticates a request against an auth service.
+//The `sub` claim is attached to the request context logger.
 func Authentication(service AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		claims, err := service.Authorize(c.Request)
 
 		if err != nil {
-			c.AbortWithStatus(http.StatusUnauthorized)
+			unauthorized(c, err)
 			return
 		}
 
-		for k, v := range claims {
-			c.Set(strings.ToLower(k), v)
-		}
+		setPrincipal(c, NewPrincipal(claims))
+
+		c.Request = c.Request.WithContext(logging.ContextWithFields(c.Request.Context(), map[string]interface{}{
+			logging.SubjectField: claims.Subject(),
+		}))
 
 		c.Next()
 	}
@@ -0,0 +1,83 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+	"github.com/getmilly/grok/models"
+)
+
+func authRouter(service api.AuthService, policies ...api.Policy) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(api.Authentication(service))
+	router.GET("/", api.Authorization(policies...), func(c *gin.Context) {
+		principal, _ := api.PrincipalFrom(c)
+		c.String(http.StatusOK, principal.Subject())
+	})
+
+	return router
+}
+
+func serve(router *gin.Engine, req *http.Request) (*httptest.ResponseRecorder, models.Error) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var body models.Error
+	json.Unmarshal(w.Body.Bytes(), &body)
+
+	return w, body
+}
+
+func TestAuthentication_MissingToken(t *testing.T) {
+	router := authRouter(hs256Service(t, []byte("a-very-long-shared-secret-value!")))
+
+	w, body := serve(router, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, api.CodeTokenMissing, body.Code)
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+}
+
+func TestAuthentication_InvalidSignature(t *testing.T) {
+	router := authRouter(hs256Service(t, []byte("a-very-long-shared-secret-value!")))
+
+	w, body := serve(router, authorizedRequest(hs256Token(t, []byte("another-secret-with-enough-bytes"))))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, api.CodeInvalidSignature, body.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+}
+
+func TestAuthorization_Policies(t *testing.T) {
+	store := api.NewMemoryAPIKeyStore(api.APIKey{
+		ID:     "batch",
+		Hash:   api.HashAPIKey("key"),
+		Scopes: []string{"orders:read"},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/?api_key=key", nil)
+
+	allowed := authRouter(api.NewAPIKeyAuthService(store), api.RequireScopes("orders:read"))
+	w, _ := serve(allowed, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "batch", w.Body.String())
+
+	denied := authRouter(api.NewAPIKeyAuthService(store), api.AnyOf(
+		api.RequireScopes("orders:write"),
+		api.RequireRole("admin"),
+	))
+	w, body := serve(denied, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, api.CodeInsufficientScope, body.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="insufficient_scope"`)
+}
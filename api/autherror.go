@@ -0,0 +1,115 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	auth0 "github.com/auth0-community/go-auth0"
+	"github.com/getmilly/grok/models"
+	"github.com/gin-gonic/gin"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+//Authentication failure codes returned in models.Error.
+const (
+	CodeTokenMissing      = "token_missing"
+	CodeTokenMalformed    = "token_malformed"
+	CodeTokenExpired      = "token_expired"
+	CodeTokenNotValidYet  = "token_not_valid_yet"
+	CodeTokenRevoked      = "token_revoked"
+	CodeInvalidSignature  = "invalid_signature"
+	CodeInvalidIssuer     = "invalid_issuer"
+	CodeInvalidAudience   = "invalid_audience"
+	CodeInvalidToken      = "invalid_token"
+	CodeInsufficientScope = "insufficient_scope"
+)
+
+var descriptions = map[string]string{
+	CodeTokenMissing:     "Missing access token",
+	CodeTokenMalformed:   "Malformed access token",
+	CodeTokenExpired:     "The access token expired",
+	CodeTokenNotValidYet: "The access token is not valid yet",
+	CodeTokenRevoked:     "The access token was revoked",
+	CodeInvalidSignature: "Invalid access token signature",
+	CodeInvalidIssuer:    "Invalid access token issuer",
+	CodeInvalidAudience:  "Invalid access token audience",
+	CodeInvalidToken:     "Invalid access token",
+}
+
+//AuthError describes why a request couldn't be authenticated.
+type AuthError struct {
+	Code        string
+	Description string
+	Cause       error
+}
+
+func (err AuthError) Error() string {
+	return fmt.Sprintf("%s: %v", err.Code, err.Cause)
+}
+
+//ClassifyAuthError maps an AuthService error to an AuthError.
+func ClassifyAuthError(err error) AuthError {
+	if authErr, ok := err.(AuthError); ok {
+		return authErr
+	}
+
+	code := classify(err)
+
+	return AuthError{
+		Code:        code,
+		Description: descriptions[code],
+		Cause:       err,
+	}
+}
+
+func classify(err error) string {
+	switch err {
+	case auth0.ErrTokenNotFound, ErrAPIKeyMissing, ErrClientCertMissing:
+		return CodeTokenMissing
+	case jwt.ErrExpired:
+		return CodeTokenExpired
+	case jwt.ErrNotValidYet:
+		return CodeTokenNotValidYet
+	case jwt.ErrInvalidIssuer:
+		return CodeInvalidIssuer
+	case jwt.ErrInvalidAudience:
+		return CodeInvalidAudience
+	case jose.ErrCryptoFailure, ErrKeyNotFound, ErrNoKeyID:
+		return CodeInvalidSignature
+	case ErrInactiveToken, ErrAPIKeyRevoked:
+		return CodeTokenRevoked
+	}
+
+	if strings.HasPrefix(err.Error(), "square/go-jose") || strings.Contains(err.Error(), "base64") {
+		return CodeTokenMalformed
+	}
+
+	return CodeInvalidToken
+}
+
+func unauthorized(c *gin.Context, err error) {
+	authErr := ClassifyAuthError(err)
+
+	c.Error(authErr)
+
+	if authErr.Code == CodeTokenMissing {
+		c.Header("WWW-Authenticate", "Bearer")
+	} else {
+		c.Header("WWW-Authenticate", challenge("invalid_token", authErr.Description))
+	}
+
+	c.AbortWithStatusJSON(http.StatusUnauthorized, models.Error{
+		Code:           authErr.Code,
+		Message:        authErr.Description,
+		HTTPStatusCode: http.StatusUnauthorized,
+	})
+}
+
+func challenge(code, description string) string {
+	description = strings.Replace(description, `\`, "", -1)
+	description = strings.Replace(description, `"`, "'", -1)
+
+	return fmt.Sprintf(`Bearer error="%s", error_description="%s"`, code, description)
+}
@@ -10,22 +10,26 @@ import (
 // CORS ...
 func CORS(allowed []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		origin := c.Request.Header.Get("Origin")
+		cors(c, allowed)
+	}
+}
 
-		switch {
-		case len(allowed) <= 0:
-			setCorsHeaders(c.Writer, "*")
-		case isAllowedOrigin(allowed, origin):
-			setCorsHeaders(c.Writer, origin)
-		}
+func cors(c *gin.Context, allowed []string) {
+	origin := c.Request.Header.Get("Origin")
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusOK)
-			return
-		}
+	switch {
+	case len(allowed) <= 0:
+		setCorsHeaders(c.Writer, "*")
+	case isAllowedOrigin(allowed, origin):
+		setCorsHeaders(c.Writer, origin)
+	}
 
-		c.Next()
+	if c.Request.Method == "OPTIONS" {
+		c.AbortWithStatus(http.StatusOK)
+		return
 	}
+
+	c.Next()
 }
 
 func setCorsHeaders(writer http.ResponseWriter, origin string) {
@@ -1,18 +1,83 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+//Check statuses reported by HealthReport.
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+//DefaultCheckTimeout bounds checks registered without a timeout.
+const DefaultCheckTimeout = 5 * time.Second
+
+var (
+	//ErrCheckTimeout is reported when a check doesn't return in time.
+	ErrCheckTimeout = errors.New("check timed out")
+)
+
 //HealthzHandler will be called to get application status
 type HealthzHandler func() interface{}
 
+//Check reports the health of a dependency, nil means healthy.
+type Check func(ctx context.Context) error
+
+//CheckOptions configures a registered check.
+type CheckOptions struct {
+	//Timeout bounds the check, DefaultCheckTimeout when zero.
+	Timeout time.Duration
+	//Critical checks answer 503 when failing, others only degrade the report.
+	Critical bool
+}
+
+//CheckResult is the outcome of one check.
+type CheckResult struct {
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+//HealthReport aggregates every check of a probe.
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
 //HealthChecks joins liveness and readiness health checks.
+//Handlers are used only while no check is registered for their probe.
 type HealthChecks struct {
 	Liveness  HealthzHandler
 	Readiness HealthzHandler
+	//CacheTTL reuses reports for this long, zero runs checks on every probe.
+	CacheTTL time.Duration
+
+	liveness  *checkGroup
+	readiness *checkGroup
+	once      sync.Once
+}
+
+type namedCheck struct {
+	name    string
+	check   Check
+	options CheckOptions
+}
+
+type checkGroup struct {
+	mu      sync.Mutex
+	checks  []namedCheck
+	report  *HealthReport
+	expires time.Time
 }
 
 //DefaultHealthz just check if the app isnt locked.
@@ -30,22 +95,204 @@ func DefaultHealthChecks() *HealthChecks {
 	}
 }
 
+//AddLivenessCheck registers a check answered by /healthz/liveness.
+func (healthz *HealthChecks) AddLivenessCheck(name string, check Check, options CheckOptions) {
+	healthz.init()
+	healthz.liveness.add(name, check, options)
+}
+
+//AddReadinessCheck registers a check answered by /healthz/readiness.
+func (healthz *HealthChecks) AddReadinessCheck(name string, check Check, options CheckOptions) {
+	healthz.init()
+	healthz.readiness.add(name, check, options)
+}
+
+//LivenessReport runs liveness checks, or returns the cached report.
+func (healthz *HealthChecks) LivenessReport(ctx context.Context) HealthReport {
+	healthz.init()
+	return healthz.liveness.run(ctx, healthz.CacheTTL)
+}
+
+//ReadinessReport runs readiness checks, or returns the cached report.
+func (healthz *HealthChecks) ReadinessReport(ctx context.Context) HealthReport {
+	healthz.init()
+	return healthz.readiness.run(ctx, healthz.CacheTTL)
+}
+
+//StatusCode is 503 when a critical check is down, 200 otherwise.
+func (report HealthReport) StatusCode() int {
+	if report.Status == StatusDown {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusOK
+}
+
+func (healthz *HealthChecks) init() {
+	healthz.once.Do(func() {
+		healthz.liveness = &checkGroup{}
+		healthz.readiness = &checkGroup{}
+	})
+}
+
+func (group *checkGroup) add(name string, check Check, options CheckOptions) {
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultCheckTimeout
+	}
+
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	group.checks = append(group.checks, namedCheck{name: name, check: check, options: options})
+	group.report = nil
+}
+
+func (group *checkGroup) empty() bool {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	return len(group.checks) == 0
+}
+
+func (group *checkGroup) run(ctx context.Context, ttl time.Duration) HealthReport {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	if group.report != nil && time.Now().Before(group.expires) {
+		return *group.report
+	}
+
+	report := HealthReport{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(group.checks)),
+	}
+
+	results := make([]CheckResult, len(group.checks))
+
+	var wg sync.WaitGroup
+
+	for i, check := range group.checks {
+		wg.Add(1)
+
+		go func(i int, check namedCheck) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check)
+		}(i, check)
+	}
+
+	wg.Wait()
+
+	for i, check := range group.checks {
+		result := results[i]
+		report.Checks[check.name] = result
+
+		if result.Status == StatusUp {
+			continue
+		}
+
+		if result.Critical {
+			report.Status = StatusDown
+		} else if report.Status == StatusUp {
+			report.Status = StatusDegraded
+		}
+	}
+
+	group.report = &report
+	group.expires = time.Now().Add(ttl)
+
+	return report
+}
+
+func runCheck(ctx context.Context, check namedCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, check.options.Timeout)
+	defer cancel()
+
+	started := time.Now()
+	done := make(chan error, 1)
+
+	go func() {
+		done <- check.check(ctx)
+	}()
+
+	var err error
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ErrCheckTimeout
+	}
+
+	result := CheckResult{
+		Status:    StatusUp,
+		Critical:  check.options.Critical,
+		LatencyMs: float64(time.Since(started)) / float64(time.Millisecond),
+		CheckedAt: started,
+	}
+
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
 func (server *Server) liveness() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var healthz interface{}
-		if server.Healthz.Liveness != nil {
-			healthz = server.Healthz.Liveness()
+		server.Healthz.init()
+
+		if server.Healthz.liveness.empty() {
+			legacyHealthz(c, server.Healthz.Liveness)
+			return
 		}
-		c.JSON(http.StatusOK, healthz)
+
+		report := server.Healthz.LivenessReport(c.Request.Context())
+		c.JSON(report.StatusCode(), report)
 	}
 }
 
 func (server *Server) readiness() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var healthz interface{}
-		if server.Healthz.Readiness != nil {
-			healthz = server.Healthz.Readiness()
+		if server.Lifecycle != nil && server.Lifecycle.ShuttingDown() {
+			c.JSON(http.StatusServiceUnavailable, false)
+			return
+		}
+
+		if startup := server.StartupReport(); startup.Status != StatusStarted {
+			c.JSON(http.StatusServiceUnavailable, startup)
+			return
 		}
-		c.JSON(http.StatusOK, healthz)
+
+		server.Healthz.init()
+
+		if server.Healthz.readiness.empty() {
+			legacyHealthz(c, server.Healthz.Readiness)
+			return
+		}
+
+		report := server.Healthz.ReadinessReport(c.Request.Context())
+		c.JSON(report.StatusCode(), report)
+	}
+}
+
+//legacyHealthz answers 503 when the handler returns false or an error.
+func legacyHealthz(c *gin.Context, handler HealthzHandler) {
+	var healthz interface{}
+
+	if handler != nil {
+		healthz = handler()
 	}
+
+	switch value := healthz.(type) {
+	case bool:
+		if !value {
+			c.JSON(http.StatusServiceUnavailable, value)
+			return
+		}
+	case error:
+		c.JSON(http.StatusServiceUnavailable, value.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, healthz)
 }
@@ -0,0 +1,82 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+)
+
+func healthy(ctx context.Context) error {
+	return nil
+}
+
+func TestHealthChecks_ReportsCriticalFailures(t *testing.T) {
+	healthz := api.DefaultHealthChecks()
+
+	healthz.AddReadinessCheck("mongo", healthy, api.CheckOptions{Critical: true})
+	healthz.AddReadinessCheck("cache", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}, api.CheckOptions{})
+
+	report := healthz.ReadinessReport(context.Background())
+
+	assert.Equal(t, api.StatusDegraded, report.Status)
+	assert.Equal(t, http.StatusOK, report.StatusCode())
+	assert.Equal(t, api.StatusUp, report.Checks["mongo"].Status)
+	assert.Equal(t, "connection refused", report.Checks["cache"].Error)
+
+	healthz.AddReadinessCheck("nats", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, api.CheckOptions{Critical: true, Timeout: 10 * time.Millisecond})
+
+	report = healthz.ReadinessReport(context.Background())
+
+	assert.Equal(t, api.StatusDown, report.Status)
+	assert.Equal(t, http.StatusServiceUnavailable, report.StatusCode())
+	assert.Equal(t, api.StatusDown, report.Checks["nats"].Status)
+}
+
+func TestHealthChecks_CachesReports(t *testing.T) {
+	var calls int32
+
+	healthz := &api.HealthChecks{CacheTTL: time.Minute}
+	healthz.AddLivenessCheck("counter", func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}, api.CheckOptions{})
+
+	healthz.LivenessReport(context.Background())
+	healthz.LivenessReport(context.Background())
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestServer_ReadinessAnswers503(t *testing.T) {
+	server := testServer(t)
+	server.Healthz.AddReadinessCheck("mongo", func(ctx context.Context) error {
+		return errors.New("no reachable servers")
+	}, api.CheckOptions{Critical: true})
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	status, body := get(t, ts.URL+"/healthz/readiness")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	var report api.HealthReport
+	assert.NoError(t, json.Unmarshal([]byte(body), &report))
+	assert.Equal(t, "no reachable servers", report.Checks["mongo"].Error)
+
+	status, _ = get(t, ts.URL+"/healthz/liveness")
+	assert.Equal(t, http.StatusOK, status)
+}
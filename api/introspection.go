@@ -0,0 +1,223 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+//DefaultIntrospectionCacheTTL caps how long active introspection responses are cached.
+const DefaultIntrospectionCacheTTL = 5 * time.Minute
+
+//maxIntrospectionCacheSize bounds the cached tokens, new ones aren't cached once reached.
+const maxIntrospectionCacheSize = 10000
+
+var (
+	//ErrInactiveToken is returned when introspection reports an inactive token.
+	ErrInactiveToken = errors.New("Token is not active")
+)
+
+//IntrospectionOptions configures an introspection auth service.
+type IntrospectionOptions struct {
+	//ClientID and ClientSecret authenticate with basic auth to the endpoint, if set.
+	ClientID     string
+	ClientSecret string
+	//Issuer and Audience, when set, must match the `iss` and `aud` of active tokens.
+	Issuer   string
+	Audience []string
+	//MaxCacheTTL caps how long active tokens are cached, until their `exp` otherwise.
+	//Tokens without `exp` aren't cached. DefaultIntrospectionCacheTTL when zero, negative disables caching.
+	MaxCacheTTL time.Duration
+	//Client is the HTTP client used to call the endpoint.
+	Client *http.Client
+}
+
+type introspection struct {
+	endpoint string
+	options  IntrospectionOptions
+
+	mu    sync.Mutex
+	cache map[string]introspected
+}
+
+type introspected struct {
+	claims  Claims
+	expires time.Time
+}
+
+//NewIntrospectionAuthService validates opaque tokens through an RFC 7662 endpoint.
+func NewIntrospectionAuthService(endpoint string, options IntrospectionOptions) AuthService {
+	if options.MaxCacheTTL == 0 {
+		options.MaxCacheTTL = DefaultIntrospectionCacheTTL
+	}
+
+	if options.Client == nil {
+		options.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &introspection{
+		endpoint: endpoint,
+		options:  options,
+		cache:    make(map[string]introspected),
+	}
+}
+
+func (service *introspection) Authorize(req *http.Request) (Claims, error) {
+	token, err := bearerToken(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := tokenHash(token)
+
+	if claims, ok := service.cached(key); ok {
+		return claims, nil
+	}
+
+	claims, err := service.introspect(req, token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.validate(claims); err != nil {
+		return nil, err
+	}
+
+	service.store(key, claims)
+
+	return claims, nil
+}
+
+func (service *introspection) introspect(req *http.Request, token string) (Claims, error) {
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+
+	introspect, err := http.NewRequest(http.MethodPost, service.endpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	introspect.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	introspect.Header.Set("Accept", "application/json")
+
+	if service.options.ClientID != "" {
+		introspect.SetBasicAuth(service.options.ClientID, service.options.ClientSecret)
+	}
+
+	resp, err := service.options.Client.Do(introspect.WithContext(req.Context()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection failed with status %d", resp.StatusCode)
+	}
+
+	var claims Claims
+
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, err
+	}
+
+	if active, _ := claims["active"].(bool); !active {
+		return nil, ErrInactiveToken
+	}
+
+	return claims, nil
+}
+
+//validate checks the issuer and audience like the JWT modes do.
+func (service *introspection) validate(claims Claims) error {
+	if service.options.Issuer != "" && claims.Issuer() != service.options.Issuer {
+		return jwt.ErrInvalidIssuer
+	}
+
+	if len(service.options.Audience) == 0 {
+		return nil
+	}
+
+	for _, audience := range claims.Audience() {
+		for _, expected := range service.options.Audience {
+			if audience == expected {
+				return nil
+			}
+		}
+	}
+
+	return jwt.ErrInvalidAudience
+}
+
+func (service *introspection) cached(key string) (Claims, bool) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	entry, ok := service.cache[key]
+
+	if !ok {
+		return nil, false
+	}
+
+	if !time.Now().Before(entry.expires) {
+		delete(service.cache, key)
+		return nil, false
+	}
+
+	return entry.claims, true
+}
+
+func (service *introspection) store(key string, claims Claims) {
+	if service.options.MaxCacheTTL < 0 {
+		return
+	}
+
+	exp := claims.ExpiresAt()
+
+	if exp.IsZero() {
+		return
+	}
+
+	now := time.Now()
+
+	if limit := now.Add(service.options.MaxCacheTTL); exp.After(limit) {
+		exp = limit
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if len(service.cache) >= maxIntrospectionCacheSize {
+		for k, entry := range service.cache {
+			if !now.Before(entry.expires) {
+				delete(service.cache, k)
+			}
+		}
+
+		if len(service.cache) >= maxIntrospectionCacheSize {
+			return
+		}
+	}
+
+	service.cache[key] = introspected{claims: claims, expires: exp}
+}
+
+//tokenHash keys the cache so raw tokens aren't kept in memory.
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	auth0 "github.com/auth0-community/go-auth0"
+	"github.com/prometheus/client_golang/prometheus"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+var (
+	//ErrKeyNotFound is returned when the token `kid` isn't in the key set.
+	ErrKeyNotFound = errors.New("Key not found")
+	//ErrNoKeyID is returned when the token header has no `kid`.
+	ErrNoKeyID = errors.New("Token has no key id")
+)
+
+//KeyCacheOptions configures how JWKS keys are cached.
+type KeyCacheOptions struct {
+	//TTL is how long a fetched key set is considered fresh.
+	TTL time.Duration
+	//MinRefreshInterval limits how often the JWKS URI is fetched.
+	//Expired keys are still served while a refresh is rate limited by it.
+	MinRefreshInterval time.Duration
+	//StaleIfError is how long expired keys are still served when a refresh fails.
+	//Keys dropped by a successful refresh are never served.
+	StaleIfError time.Duration
+	//Client is the HTTP client used to fetch keys.
+	Client *http.Client
+}
+
+//KeyCacheStats reports JWKS cache usage.
+type KeyCacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	StaleHits     uint64
+	Refreshes     uint64
+	RefreshErrors uint64
+}
+
+//JWKSCache keeps JWKS keys in memory and refreshes them when needed.
+type JWKSCache struct {
+	uri     string
+	options KeyCacheOptions
+
+	mu          sync.RWMutex
+	keys        map[string]jose.JSONWebKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastErr     error
+	refreshing  sync.Mutex
+
+	hits          uint64
+	misses        uint64
+	staleHits     uint64
+	refreshes     uint64
+	refreshErrors uint64
+
+	statsDesc *prometheus.Desc
+}
+
+//DefaultKeyCacheOptions returns sane defaults for a JWKS cache.
+func DefaultKeyCacheOptions() KeyCacheOptions {
+	return KeyCacheOptions{
+		TTL:                time.Hour,
+		MinRefreshInterval: 30 * time.Second,
+		StaleIfError:       24 * time.Hour,
+		Client:             &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+//NewJWKSCache creates a key cache for the given JWKS URI.
+func NewJWKSCache(uri string, options KeyCacheOptions) *JWKSCache {
+	if options.Client == nil {
+		options.Client = DefaultKeyCacheOptions().Client
+	}
+
+	return &JWKSCache{
+		uri:     uri,
+		options: options,
+		keys:    make(map[string]jose.JSONWebKey),
+		statsDesc: prometheus.NewDesc(
+			"grok_jwks_cache_total",
+			"JWKS cache operations by result.",
+			[]string{"result"},
+			prometheus.Labels{"uri": uri},
+		),
+	}
+}
+
+//GetSecret implements auth0.SecretProvider using the token `kid`.
+func (cache *JWKSCache) GetSecret(req *http.Request) (interface{}, error) {
+	token, err := auth0.FromHeader(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(token.Headers) < 1 || token.Headers[0].KeyID == "" {
+		return nil, ErrNoKeyID
+	}
+
+	key, err := cache.Key(token.Headers[0].KeyID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Key, nil
+}
+
+//Key returns the key identified by kid, fetching the key set if needed.
+func (cache *JWKSCache) Key(kid string) (jose.JSONWebKey, error) {
+	key, found, fresh := cache.lookup(kid)
+
+	if found && fresh {
+		atomic.AddUint64(&cache.hits, 1)
+		return key, nil
+	}
+
+	atomic.AddUint64(&cache.misses, 1)
+
+	err := cache.Refresh()
+
+	//Without error the key set is either fresh or from the last successful fetch,
+	//when the refresh was rate limited, so a key still in it can be served.
+	if refreshed, ok, fresh := cache.lookup(kid); ok && (fresh || err == nil) {
+		return refreshed, nil
+	}
+
+	if err != nil {
+		if found && cache.servesStale() {
+			atomic.AddUint64(&cache.staleHits, 1)
+			return key, nil
+		}
+
+		return jose.JSONWebKey{}, err
+	}
+
+	return jose.JSONWebKey{}, ErrKeyNotFound
+}
+
+//Refresh fetches the key set unless it was attempted too recently.
+func (cache *JWKSCache) Refresh() error {
+	cache.refreshing.Lock()
+	defer cache.refreshing.Unlock()
+
+	cache.mu.RLock()
+	lastAttempt, lastErr := cache.lastAttempt, cache.lastErr
+	cache.mu.RUnlock()
+
+	if time.Since(lastAttempt) < cache.options.MinRefreshInterval {
+		return lastErr
+	}
+
+	keys, err := cache.fetch()
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.lastAttempt = time.Now()
+	cache.lastErr = err
+
+	if err != nil {
+		atomic.AddUint64(&cache.refreshErrors, 1)
+		return err
+	}
+
+	atomic.AddUint64(&cache.refreshes, 1)
+	cache.keys = keys
+	cache.fetchedAt = cache.lastAttempt
+
+	return nil
+}
+
+//Stats returns a snapshot of cache counters.
+func (cache *JWKSCache) Stats() KeyCacheStats {
+	return KeyCacheStats{
+		Hits:          atomic.LoadUint64(&cache.hits),
+		Misses:        atomic.LoadUint64(&cache.misses),
+		StaleHits:     atomic.LoadUint64(&cache.staleHits),
+		Refreshes:     atomic.LoadUint64(&cache.refreshes),
+		RefreshErrors: atomic.LoadUint64(&cache.refreshErrors),
+	}
+}
+
+//Describe implements prometheus.Collector.
+func (cache *JWKSCache) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cache.statsDesc
+}
+
+//Collect implements prometheus.Collector.
+func (cache *JWKSCache) Collect(ch chan<- prometheus.Metric) {
+	stats := cache.Stats()
+
+	values := map[string]uint64{
+		"hit":           stats.Hits,
+		"miss":          stats.Misses,
+		"stale_hit":     stats.StaleHits,
+		"refresh":       stats.Refreshes,
+		"refresh_error": stats.RefreshErrors,
+	}
+
+	for result, value := range values {
+		ch <- prometheus.MustNewConstMetric(cache.statsDesc, prometheus.CounterValue, float64(value), result)
+	}
+}
+
+func (cache *JWKSCache) lookup(kid string) (jose.JSONWebKey, bool, bool) {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	key, found := cache.keys[kid]
+	fresh := time.Since(cache.fetchedAt) < cache.options.TTL
+
+	return key, found, fresh
+}
+
+func (cache *JWKSCache) servesStale() bool {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return time.Since(cache.fetchedAt) < cache.options.TTL+cache.options.StaleIfError
+}
+
+func (cache *JWKSCache) fetch() (map[string]jose.JSONWebKey, error) {
+	resp, err := cache.options.Client.Get(cache.uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks fetch failed with status %d", resp.StatusCode)
+	}
+
+	var set jose.JSONWebKeySet
+
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]jose.JSONWebKey)
+
+	for _, key := range set.Keys {
+		if key.Use == "" || key.Use == "sig" {
+			keys[key.KeyID] = key
+		}
+	}
+
+	return keys, nil
+}
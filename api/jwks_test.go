@@ -0,0 +1,194 @@
+package api_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/getmilly/grok/api"
+)
+
+type jwksServer struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	requests int32
+	failing  int32
+	rotated  int32
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	server := &jwksServer{key: key}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&server.requests, 1)
+
+		if atomic.LoadInt32(&server.failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		kid := "test"
+
+		if atomic.LoadInt32(&server.rotated) == 1 {
+			kid = "next"
+		}
+
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: kid, Algorithm: "RS256", Use: "sig"}},
+		})
+	}))
+
+	return server
+}
+
+func (server *jwksServer) token(t *testing.T, kid string) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: server.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", kid),
+	)
+	assert.NoError(t, err)
+
+	raw, err := jwt.Signed(signer).Claims(jwt.Claims{
+		Subject:  "user",
+		Issuer:   "issuer",
+		Audience: jwt.Audience{"audience"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).CompactSerialize()
+	assert.NoError(t, err)
+
+	return raw
+}
+
+func authorizedRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWKSCache_ReusesKeysAcrossRequests(t *testing.T) {
+	server := newJWKSServer(t)
+	defer server.Close()
+
+	cache := api.NewJWKSCache(server.URL, api.DefaultKeyCacheOptions())
+	service := api.NewCachedAuthService(cache, "issuer", []string{"audience"})
+	token := server.token(t, "test")
+
+	for i := 0; i < 5; i++ {
+		claims, err := service.Authorize(authorizedRequest(token))
+		assert.NoError(t, err)
+		assert.Equal(t, "user", claims["sub"])
+	}
+
+	stats := cache.Stats()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.requests))
+	assert.Equal(t, uint64(1), stats.Refreshes)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.True(t, stats.Hits > 0)
+}
+
+func TestJWKSCache_UnknownKeyIsRateLimited(t *testing.T) {
+	server := newJWKSServer(t)
+	defer server.Close()
+
+	cache := api.NewJWKSCache(server.URL, api.DefaultKeyCacheOptions())
+	service := api.NewCachedAuthService(cache, "issuer", []string{"audience"})
+	token := server.token(t, "unknown")
+
+	for i := 0; i < 3; i++ {
+		_, err := service.Authorize(authorizedRequest(token))
+		assert.Error(t, err)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.requests))
+}
+
+func TestJWKSCache_ServesStaleKeysWhenRefreshFails(t *testing.T) {
+	server := newJWKSServer(t)
+	defer server.Close()
+
+	options := api.DefaultKeyCacheOptions()
+	options.TTL = time.Millisecond
+	options.MinRefreshInterval = 0
+
+	cache := api.NewJWKSCache(server.URL, options)
+	service := api.NewCachedAuthService(cache, "issuer", []string{"audience"})
+	token := server.token(t, "test")
+
+	_, err := service.Authorize(authorizedRequest(token))
+	assert.NoError(t, err)
+
+	atomic.StoreInt32(&server.failing, 1)
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = service.Authorize(authorizedRequest(token))
+	assert.NoError(t, err)
+
+	stats := cache.Stats()
+	assert.True(t, stats.StaleHits > 0)
+	assert.True(t, stats.RefreshErrors > 0)
+}
+
+func TestJWKSCache_RejectsKeysDroppedByRefresh(t *testing.T) {
+	server := newJWKSServer(t)
+	defer server.Close()
+
+	options := api.DefaultKeyCacheOptions()
+	options.TTL = time.Millisecond
+	options.MinRefreshInterval = 0
+
+	cache := api.NewJWKSCache(server.URL, options)
+	service := api.NewCachedAuthService(cache, "issuer", []string{"audience"})
+	token := server.token(t, "test")
+
+	_, err := service.Authorize(authorizedRequest(token))
+	assert.NoError(t, err)
+
+	atomic.StoreInt32(&server.rotated, 1)
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = service.Authorize(authorizedRequest(token))
+	assert.Error(t, err)
+
+	_, err = cache.Key("test")
+	assert.Equal(t, api.ErrKeyNotFound, err)
+	assert.Equal(t, uint64(0), cache.Stats().StaleHits)
+}
+
+func TestJWKSCache_ServesExpiredKeysWhileRefreshIsRateLimited(t *testing.T) {
+	server := newJWKSServer(t)
+	defer server.Close()
+
+	options := api.DefaultKeyCacheOptions()
+	options.TTL = time.Millisecond
+	options.MinRefreshInterval = time.Hour
+
+	cache := api.NewJWKSCache(server.URL, options)
+	service := api.NewCachedAuthService(cache, "issuer", []string{"audience"})
+	token := server.token(t, "test")
+
+	_, err := service.Authorize(authorizedRequest(token))
+	assert.NoError(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		_, err = service.Authorize(authorizedRequest(token))
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.requests))
+
+	_, err = cache.Key("unknown")
+	assert.Equal(t, api.ErrKeyNotFound, err)
+}
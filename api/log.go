@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
-	"io/ioutil"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,92 +16,350 @@ import (
 	uuid "github.com/satori/go.uuid"
 )
 
+//DefaultMaxBodySize is the number of body bytes logged by default.
+const DefaultMaxBodySize = 4096
+
+var skipAccessLogKey = "skip-access-log"
+
+//DefaultRedactedQueryParams carry credentials, e.g. the API key accepted by NewAPIKeyAuthService.
+var DefaultRedactedQueryParams = []string{"api_key", "access_token", "password"}
+
+//AccessLogOptions configures AccessLog.
+type AccessLogOptions struct {
+	//BasePath is trimmed before matching SkipPaths.
+	BasePath string
+	//SkipPaths aren't logged, a trailing `*` matches any suffix, see also SkipAccessLog.
+	SkipPaths []string
+	//MaxBodySize in bytes of request and response bodies logged, DefaultMaxBodySize when zero.
+	//Only textual bodies, e.g. JSON, are logged. A negative size disables body logging.
+	//Truncated JSON and form bodies are replaced by `body_truncated` and the size.
+	MaxBodySize int
+	//SampleRate of requests answered with a status below 400 logged, from 0 to 1, 1 when zero.
+	SampleRate float64
+	//SlowThreshold logs slower requests as warnings even if not sampled, disabled when zero.
+	SlowThreshold time.Duration
+	//RedactedQueryParams are masked in the URL, query string and form values, case insensitive,
+	//DefaultRedactedQueryParams when nil.
+	RedactedQueryParams []string
+}
+
+//queryParams masks denied query and form parameters.
+type queryParams map[string]bool
+
+type capture struct {
+	body      bytes.Buffer
+	limit     int
+	size      int
+	truncated bool
+}
+
 type bodyLogWriter struct {
 	gin.ResponseWriter
-	body *bytes.Buffer
+	capture
+}
+
+type bodyLogReader struct {
+	io.ReadCloser
+	capture
+}
+
+func (b *capture) write(p []byte) {
+	b.size += len(p)
+	room := b.limit - b.body.Len()
+
+	if room < 0 {
+		room = 0
+	}
+
+	if len(p) > room {
+		b.truncated = b.truncated || b.limit >= 0
+		p = p[:room]
+	}
+
+	b.body.Write(p)
 }
 
 func (w *bodyLogWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+	w.write(b)
 	return w.ResponseWriter.Write(b)
 }
 
-//Logging ...
+func (w *bodyLogWriter) WriteString(s string) (int, error) {
+	w.write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (r *bodyLogReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.write(p[:n])
+	return n, err
+}
+
+//RequestIDHeader carries the request ID, reused when sent by the client.
+const RequestIDHeader = "Request-Id"
+
+//Logging logs requests with the default AccessLogOptions.
 func Logging() gin.HandlerFunc {
+	return AccessLog(AccessLogOptions{})
+}
+
+//AccessLog logs requests and attaches the request ID to the request context logger,
+//see logging.FromContext. Requests answered with 5xx are logged as errors and slow
+//requests as warnings, even if not sampled, unless the path is skipped.
+func AccessLog(options AccessLogOptions) gin.HandlerFunc {
+	if options.MaxBodySize == 0 {
+		options.MaxBodySize = DefaultMaxBodySize
+	}
+
+	if options.SampleRate <= 0 || options.SampleRate > 1 {
+		options.SampleRate = 1
+	}
+
+	if options.RedactedQueryParams == nil {
+		options.RedactedQueryParams = DefaultRedactedQueryParams
+	}
+
+	params := make(queryParams)
+
+	for _, param := range options.RedactedQueryParams {
+		params[strings.ToLower(param)] = true
+	}
+
+	basePath := strings.TrimSuffix(options.BasePath, "/")
+
 	return func(c *gin.Context) {
 		defer recovery()
 		defer c.Request.Body.Close()
 
-		requestID := uuid.NewV4()
+		requestID := requestID(c.Request)
 
-		blw := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
-		blw.Header().Set("Request-Id", requestID.String())
+		blw := &bodyLogWriter{ResponseWriter: c.Writer, capture: capture{limit: options.MaxBodySize}}
+		blw.Header().Set(RequestIDHeader, requestID)
 		c.Writer = blw
 
+		blr := &bodyLogReader{ReadCloser: c.Request.Body, capture: capture{limit: options.MaxBodySize}}
+
+		if !textual(c.Request.Header.Get("Content-Type")) {
+			blr.limit = -1
+		}
+
+		c.Request.Body = blr
+
+		c.Request = c.Request.WithContext(logging.ContextWithFields(c.Request.Context(), map[string]interface{}{
+			logging.RequestIDField: requestID,
+		}))
+
 		now := time.Now()
-		req := request(c)
+		req := request(c, params)
 
 		c.Next()
 
 		elapsed := time.Since(now)
+		status := c.Writer.Status()
+		slow := options.SlowThreshold > 0 && elapsed >= options.SlowThreshold
+
+		if _, skip := c.Get(skipAccessLogKey); skip || matchPath(options.SkipPaths, strings.TrimPrefix(c.Request.URL.Path, basePath)) {
+			return
+		}
+
+		if status < http.StatusBadRequest && !slow && rand.Float64() >= options.SampleRate {
+			return
+		}
+
+		req["body"] = body(&blr.capture, c.Request.Header.Get("Content-Type"), params)
+		req["form"] = params.redact(c.Request.Form)
+		req["post_form"] = params.redact(c.Request.PostForm)
+
+		if blr.truncated {
+			req["body_truncated"] = true
+			req["size"] = blr.size
+		}
+
 		fields := make(map[string]interface{})
 
 		fields["request"] = req
-		fields["claims"] = c.Keys
+
+		if claims, ok := ClaimsFrom(c); ok {
+			fields["claims"] = claims
+		}
 		fields["errors"] = c.Errors
 		fields["ip"] = c.ClientIP()
 		fields["latency"] = elapsed.Seconds()
-		fields["request_id"] = requestID.String()
-		fields["response"] = blw
+		fields["response"] = response(blw, params)
+
+		entry := logging.FromContext(c.Request.Context()).With(fields)
+		log := entry.Info
 
-		logging.LogWith(fields).Info(
+		switch {
+		case status >= http.StatusInternalServerError:
+			log = entry.Error
+		case slow:
+			log = entry.Warn
+		}
+
+		log(
 			"Request incoming from %s elapsed %s completed with %d",
 			c.ClientIP(),
 			elapsed.String(),
-			c.Writer.Status(),
+			status,
 		)
 	}
 }
 
-func request(context *gin.Context) interface{} {
-	r := make(map[string]interface{})
+//SkipAccessLog opts a route out of the access log, e.g. `router.GET("/ping", api.SkipAccessLog(), ping)`.
+func SkipAccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(skipAccessLogKey, true)
+	}
+}
+
+func requestID(r *http.Request) string {
+	for _, header := range []string{RequestIDHeader, "X-Request-Id"} {
+		if id := r.Header.Get(header); id != "" && len(id) <= 128 {
+			return id
+		}
+	}
+
+	return uuid.NewV4().String()
+}
 
-	bodyCopy := new(bytes.Buffer)
-	io.Copy(bodyCopy, context.Request.Body)
-	bodyData := bodyCopy.Bytes()
+//request returns the request fields but the body and forms, captured while the handlers read them.
+func request(context *gin.Context, params queryParams) map[string]interface{} {
+	r := make(map[string]interface{})
 
-	var body map[string]interface{}
-	json.Unmarshal(bodyData, &body)
+	redactor := logging.Default().Redactor()
 
-	r["body"] = body
-	r["headers"] = context.Request.Header
+	r["headers"] = redactor.Headers(context.Request.Header)
 	r["host"] = context.Request.Host
-	r["form"] = context.Request.Form
 	r["path"] = context.Request.URL.Path
 	r["method"] = context.Request.Method
-	r["url"] = context.Request.URL.String()
-	r["post_form"] = context.Request.PostForm
+	r["url"] = params.url(context.Request.URL)
 	r["remote_addr"] = context.Request.RemoteAddr
-	r["query_string"] = context.Request.URL.Query()
-
-	context.Request.Body = ioutil.NopCloser(bytes.NewReader(bodyData))
+	r["query_string"] = params.redact(context.Request.URL.Query())
 
 	return r
 }
 
-func response(writer *bodyLogWriter) interface{} {
+func response(writer *bodyLogWriter, params queryParams) map[string]interface{} {
 	r := make(map[string]interface{})
 
-	var body map[string]interface{}
-	json.Unmarshal(writer.body.Bytes(), &body)
+	redactor := logging.Default().Redactor()
+
+	if !textual(writer.Header().Get("Content-Type")) {
+		writer.body.Reset()
+		writer.truncated = false
+	}
 
-	r["body"] = body
+	r["body"] = body(&writer.capture, writer.Header().Get("Content-Type"), params)
 	r["status"] = writer.Status()
-	r["headers"] = writer.Header()
+	r["headers"] = redactor.Headers(writer.Header())
+	r["size"] = writer.Size()
+
+	if writer.truncated {
+		r["body_truncated"] = true
+	}
 
 	return r
 }
 
+//body returns the captured JSON or form body decoded, or as text, redacted.
+//Truncated JSON and form bodies aren't logged since redaction paths can't apply to them.
+func body(captured *capture, contentType string, params queryParams) interface{} {
+	if captured.body.Len() == 0 {
+		return nil
+	}
+
+	if captured.truncated && (jsonContent(contentType) || formContent(contentType)) {
+		return nil
+	}
+
+	redactor := logging.Default().Redactor()
+
+	if jsonContent(contentType) {
+		var v interface{}
+
+		if err := json.Unmarshal(captured.body.Bytes(), &v); err == nil {
+			return redactor.Value(v)
+		}
+	}
+
+	if formContent(contentType) {
+		if values, err := url.ParseQuery(captured.body.String()); err == nil {
+			return params.redact(values)
+		}
+	}
+
+	return redactor.Value(captured.body.String())
+}
+
+func textual(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"), jsonContent(contentType), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/xml", "application/x-www-form-urlencoded", "application/javascript", "application/graphql":
+		return true
+	}
+
+	return false
+}
+
+func jsonContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+func formContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	return err == nil && mediaType == "application/x-www-form-urlencoded"
+}
+
+//redact returns values with denied parameters and redaction paths masked, e.g. `$.password`.
+func (params queryParams) redact(values url.Values) interface{} {
+	if values == nil {
+		return nil
+	}
+
+	return logging.Default().Redactor().Value(params.mask(values))
+}
+
+func (params queryParams) mask(values url.Values) url.Values {
+	masked := make(url.Values, len(values))
+
+	for key, value := range values {
+		if params[strings.ToLower(key)] {
+			masked[key] = []string{logging.Mask}
+			continue
+		}
+
+		masked[key] = value
+	}
+
+	return masked
+}
+
+//url returns u with denied query parameters masked.
+func (params queryParams) url(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+
+	masked := *u
+	masked.RawQuery = params.mask(u.Query()).Encode()
+
+	return strings.Replace(masked.String(), url.QueryEscape(logging.Mask), logging.Mask, -1)
+}
+
 func marshal(v interface{}) string {
 	b, _ := json.Marshal(v)
 	return string(b)
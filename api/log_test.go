@@ -0,0 +1,319 @@
+package api_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarulabs/di"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+	"github.com/getmilly/grok/logging"
+)
+
+type correlatedController struct{}
+
+func (ctrl *correlatedController) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/orders/:id", func(c *gin.Context) {
+		log := logging.FromContext(c.Request.Context())
+
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"request_id": log.Field(logging.RequestIDField),
+			"trace_id":   log.Field(logging.TraceIDField),
+			"route":      log.Field(logging.RouteField),
+		})
+	})
+
+	router.POST("/signups", func(c *gin.Context) {
+		var body map[string]interface{}
+
+		if err := c.ShouldBindJSON(&body); err != nil {
+			api.BindingError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, body)
+	})
+}
+
+func TestLogging_AttachesCorrelationToRequestContext(t *testing.T) {
+	server := api.ConfigureServer(func() *api.Settings {
+		return &api.Settings{Host: "127.0.0.1:0"}
+	}, api.DefaultHealthChecks())
+
+	err := server.AddController(di.Def{
+		Name:  "correlated-controller",
+		Scope: di.App,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return &correlatedController{}, nil
+		},
+	})
+	assert.NoError(t, err)
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/orders/42", nil)
+	req.Header.Set(api.RequestIDHeader, "request-42")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, "request-42", resp.Header.Get(api.RequestIDHeader))
+	assert.JSONEq(t, `{
+		"request_id": "request-42",
+		"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"route": "/orders/:id"
+	}`, string(body))
+}
+
+func TestLogging_GeneratesRequestID(t *testing.T) {
+	server := api.ConfigureServer(func() *api.Settings {
+		return &api.Settings{Host: "127.0.0.1:0"}
+	}, api.DefaultHealthChecks())
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/healthz")
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Len(t, resp.Header.Get(api.RequestIDHeader), 36)
+}
+
+func TestLogging_RedactsSensitiveData(t *testing.T) {
+	sink := logging.NewMemorySink()
+	logger, _ := logging.New(logging.Config{Sinks: []io.Writer{sink}})
+	defer logging.SetDefault(logging.Default())
+
+	server := api.ConfigureServer(func() *api.Settings {
+		return &api.Settings{
+			Host:           "127.0.0.1:0",
+			Logger:         logger,
+			LogRedactPaths: []string{"$.card.number"},
+		}
+	}, api.DefaultHealthChecks())
+
+	err := server.AddController(di.Def{
+		Name:  "correlated-controller",
+		Scope: di.App,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return &correlatedController{}, nil
+		},
+	})
+	assert.NoError(t, err)
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/signups", strings.NewReader(`{
+		"password": "hunter2",
+		"card": {"number": "4111111111111111"},
+		"email": "john@example.com"
+	}`))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.NotEmpty(t, sink.Entries())
+
+	output := sink.String()
+
+	assert.NotContains(t, output, "secret-token")
+	assert.NotContains(t, output, "hunter2")
+	assert.NotContains(t, output, "4111111111111111")
+	assert.NotContains(t, output, "john@example.com")
+	assert.Contains(t, output, logging.Mask)
+}
+
+func accessLogEngine(options api.AccessLogOptions) (*gin.Engine, *logging.MemorySink) {
+	sink := logging.NewMemorySink()
+	logger, _ := logging.New(logging.Config{Sinks: []io.Writer{sink}})
+	logging.SetDefault(logger)
+
+	engine := gin.New()
+	engine.Use(api.AccessLog(options))
+
+	engine.POST("/echo", func(c *gin.Context) {
+		body, _ := ioutil.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, c.ContentType(), body)
+	})
+	engine.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	engine.POST("/form", func(c *gin.Context) {
+		c.String(http.StatusOK, c.PostForm("name"))
+	})
+	engine.GET("/quiet", api.SkipAccessLog(), func(c *gin.Context) {
+		c.String(http.StatusInternalServerError, "quiet")
+	})
+	engine.GET("/fail", func(c *gin.Context) {
+		c.String(http.StatusInternalServerError, "fail")
+	})
+
+	return engine, sink
+}
+
+func logRequest(engine *gin.Engine, method, path, contentType, body string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestAccessLog_CapturesCappedTextualBodies(t *testing.T) {
+	defer logging.SetDefault(logging.Default())
+
+	engine, sink := accessLogEngine(api.AccessLogOptions{MaxBodySize: 8})
+
+	logRequest(engine, http.MethodPost, "/echo", "text/plain", "hello world")
+	logRequest(engine, http.MethodPost, "/echo", "application/octet-stream", "binary")
+
+	entries := sink.Entries()
+	assert.Len(t, entries, 2)
+
+	fields := entries[0].Fields["data"].(map[string]interface{})
+	req := fields["request"].(map[string]interface{})
+	resp := fields["response"].(map[string]interface{})
+
+	assert.Equal(t, "hello wo", req["body"])
+	assert.Equal(t, true, req["body_truncated"])
+	assert.Equal(t, "hello wo", resp["body"])
+	assert.Equal(t, http.StatusOK, resp["status"])
+	assert.Equal(t, 11, resp["size"])
+
+	fields = entries[1].Fields["data"].(map[string]interface{})
+	assert.Nil(t, fields["request"].(map[string]interface{})["body"])
+	assert.Nil(t, fields["response"].(map[string]interface{})["body"])
+	assert.Equal(t, 6, fields["response"].(map[string]interface{})["size"])
+}
+
+func TestAccessLog_SamplesSuccessfulRequests(t *testing.T) {
+	defer logging.SetDefault(logging.Default())
+
+	engine, sink := accessLogEngine(api.AccessLogOptions{
+		SampleRate: 0.000001,
+		SkipPaths:  []string{"/echo"},
+	})
+
+	logRequest(engine, http.MethodGet, "/ping", "", "")
+	logRequest(engine, http.MethodGet, "/quiet", "", "")
+	logRequest(engine, http.MethodPost, "/echo", "text/plain", "skipped")
+	logRequest(engine, http.MethodGet, "/fail", "", "")
+
+	entries := sink.Entries()
+
+	assert.Len(t, entries, 1)
+	assert.Equal(t, logging.ErrorLevel, entries[0].Level)
+}
+
+func TestAccessLog_AlwaysLogsSlowRequests(t *testing.T) {
+	defer logging.SetDefault(logging.Default())
+
+	engine, sink := accessLogEngine(api.AccessLogOptions{
+		SampleRate:    0.000001,
+		SlowThreshold: time.Nanosecond,
+	})
+
+	logRequest(engine, http.MethodGet, "/ping", "", "")
+
+	entries := sink.Entries()
+
+	assert.Len(t, entries, 1)
+	assert.Equal(t, logging.WarnLevel, entries[0].Level)
+}
+
+func TestAccessLog_RedactsQueryParameters(t *testing.T) {
+	defer logging.SetDefault(logging.Default())
+
+	engine, sink := accessLogEngine(api.AccessLogOptions{})
+
+	logRequest(engine, http.MethodGet, "/ping?api_key=k-123&Access_Token=t-456&page=2", "", "")
+
+	entries := sink.Entries()
+	assert.Len(t, entries, 1)
+
+	output := sink.String()
+	req := entries[0].Fields["data"].(map[string]interface{})["request"].(map[string]interface{})
+
+	assert.NotContains(t, output, "k-123")
+	assert.NotContains(t, output, "t-456")
+	assert.Equal(t, "/ping?Access_Token="+logging.Mask+"&api_key="+logging.Mask+"&page=2", req["url"])
+	assert.Equal(t, []interface{}{"2"}, req["query_string"].(map[string]interface{})["page"])
+}
+
+func TestAccessLog_RedactsConfiguredQueryParameters(t *testing.T) {
+	defer logging.SetDefault(logging.Default())
+
+	engine, sink := accessLogEngine(api.AccessLogOptions{RedactedQueryParams: []string{"signature"}})
+
+	logRequest(engine, http.MethodGet, "/ping?signature=s-789&page=2", "", "")
+
+	assert.Len(t, sink.Entries(), 1)
+	assert.NotContains(t, sink.String(), "s-789")
+	assert.Contains(t, sink.String(), "page=2")
+}
+
+func TestAccessLog_RedactsFormBodies(t *testing.T) {
+	defer logging.SetDefault(logging.Default())
+
+	engine, sink := accessLogEngine(api.AccessLogOptions{})
+
+	redactor, err := logging.NewRedactor(logging.RedactionOptions{Paths: []string{"$.pin"}})
+	assert.NoError(t, err)
+	logging.Default().SetRedactor(redactor)
+
+	logRequest(engine, http.MethodPost, "/form", "application/x-www-form-urlencoded", "name=john&password=hunter2&pin=pin-4321")
+
+	entries := sink.Entries()
+	assert.Len(t, entries, 1)
+
+	output := sink.String()
+	req := entries[0].Fields["data"].(map[string]interface{})["request"].(map[string]interface{})
+
+	assert.NotContains(t, output, "hunter2")
+	assert.NotContains(t, output, "pin-4321")
+	assert.Equal(t, []interface{}{"john"}, req["body"].(map[string]interface{})["name"])
+	assert.Equal(t, logging.Mask, req["post_form"].(map[string]interface{})["pin"])
+	assert.Equal(t, []interface{}{logging.Mask}, req["form"].(map[string]interface{})["password"])
+}
+
+func TestAccessLog_OmitsTruncatedStructuredBodies(t *testing.T) {
+	defer logging.SetDefault(logging.Default())
+
+	engine, sink := accessLogEngine(api.AccessLogOptions{MaxBodySize: 24})
+
+	logRequest(engine, http.MethodPost, "/echo", "application/json", `{"name":"john","password":"hunter2"}`)
+	logRequest(engine, http.MethodPost, "/echo", "application/x-www-form-urlencoded", "name=john&password=hunter2")
+
+	entries := sink.Entries()
+	assert.Len(t, entries, 2)
+	assert.NotContains(t, sink.String(), "hunter")
+
+	for _, entry := range entries {
+		fields := entry.Fields["data"].(map[string]interface{})
+		req := fields["request"].(map[string]interface{})
+		resp := fields["response"].(map[string]interface{})
+
+		assert.Nil(t, req["body"])
+		assert.Equal(t, true, req["body_truncated"])
+		assert.NotNil(t, req["size"])
+		assert.Nil(t, resp["body"])
+		assert.Equal(t, true, resp["body_truncated"])
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/getmilly/grok/lifecycle"
+	"github.com/getmilly/grok/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/sarulabs/di"
+)
+
+//LoggerDef is the DI name of the *logging.Logger used by the server.
+const LoggerDef = "logger"
+
+//LogLevel is the body of the /log/level admin endpoint.
+type LogLevel struct {
+	Level string `json:"level" binding:"required"`
+}
+
+func (server *Server) configureLogger() {
+	server.Logger = logging.Default()
+
+	if server.Settings.Logger != nil {
+		server.Logger = server.Settings.Logger
+		logging.SetDefault(server.Logger)
+
+		server.Lifecycle.Register(lifecycle.StageLogging, "logger", func(ctx context.Context) error {
+			return server.Logger.Close()
+		})
+	}
+
+	server.Logger.SetApplicationName(server.Settings.ApplicationName)
+
+	redactor, err := logging.NewRedactor(server.Settings.redactionOptions())
+
+	if err != nil {
+		panic(err)
+	}
+
+	server.Logger.SetRedactor(redactor)
+
+	if server.Settings.LogLevel != "" {
+		if err := server.Logger.SetLevel(server.Settings.LogLevel); err != nil {
+			panic(err)
+		}
+	}
+
+	err = server.DIBuilder.Add(di.Def{
+		Name:  LoggerDef,
+		Scope: di.App,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return server.Logger, nil
+		},
+	})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (server *Server) logLevel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, LogLevel{Level: server.Logger.Level().String()})
+	}
+}
+
+func (server *Server) setLogLevel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body LogLevel
+
+		if err := c.ShouldBindJSON(&body); err != nil {
+			BindingError(c, err)
+			return
+		}
+
+		if err := server.Logger.SetLevel(body.Level); err != nil {
+			BindingError(c, err)
+			return
+		}
+
+		server.Logger.WithField("level", body.Level).Info("log level changed")
+
+		c.JSON(http.StatusOK, LogLevel{Level: server.Logger.Level().String()})
+	}
+}
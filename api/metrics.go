@@ -1,12 +1,180 @@
 package api
 
 import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+var (
+	//DefaultMetricsSkipPaths aren't measured by RequestMetrics.
+	DefaultMetricsSkipPaths = []string{"/healthz/*", "/metrics"}
+)
+
+//UnmatchedRoute labels requests that didn't match any route.
+const UnmatchedRoute = "unmatched"
+
+//MetricsOptions configures RequestMetrics.
+type MetricsOptions struct {
+	//Buckets of the latency histogram, prometheus.DefBuckets when empty.
+	Buckets []float64
+	//BasePath is trimmed before matching SkipPaths.
+	BasePath string
+	//SkipPaths aren't measured, nil means DefaultMetricsSkipPaths.
+	SkipPaths []string
+	//Registerer defaults to prometheus.DefaultRegisterer.
+	Registerer prometheus.Registerer
+}
+
+type requestMetrics struct {
+	routes   *routeTable
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inflight *prometheus.GaugeVec
+}
+
+//routeTable finds the registered route matching a request, gin 1.3 has no FullPath.
+type routeTable struct {
+	engine *gin.Engine
+	once   sync.Once
+	routes []route
+}
+
+type route struct {
+	method   string
+	path     string
+	segments []string
+}
+
+//RequestMetrics records request count, latency and in-flight requests labeled by
+//method, route template, e.g. `/users/:id`, and status class, e.g. `2xx`.
+func RequestMetrics(engine *gin.Engine, options MetricsOptions) gin.HandlerFunc {
+	if len(options.Buckets) == 0 {
+		options.Buckets = prometheus.DefBuckets
+	}
+
+	if options.SkipPaths == nil {
+		options.SkipPaths = DefaultMetricsSkipPaths
+	}
+
+	if options.Registerer == nil {
+		options.Registerer = prometheus.DefaultRegisterer
+	}
+
+	metrics := &requestMetrics{
+		routes: &routeTable{engine: engine},
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grok_http_requests_total",
+			Help: "HTTP requests by method, route and status class.",
+		}, []string{"method", "route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grok_http_request_duration_seconds",
+			Help:    "HTTP request latency by method, route and status class.",
+			Buckets: options.Buckets,
+		}, []string{"method", "route", "status"}),
+		inflight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "grok_http_requests_in_flight",
+			Help: "HTTP requests being served by method and route.",
+		}, []string{"method", "route"}),
+	}
+
+	metrics.requests = mustRegister(options.Registerer, metrics.requests).(*prometheus.CounterVec)
+	metrics.latency = mustRegister(options.Registerer, metrics.latency).(*prometheus.HistogramVec)
+	metrics.inflight = mustRegister(options.Registerer, metrics.inflight).(*prometheus.GaugeVec)
+
+	return SkipPaths(options.BasePath, options.SkipPaths, metrics.handle)
+}
+
+func (metrics *requestMetrics) handle(c *gin.Context) {
+	method := c.Request.Method
+	template := metrics.routes.template(c)
+	started := time.Now()
+
+	inflight := metrics.inflight.WithLabelValues(method, template)
+	inflight.Inc()
+	defer inflight.Dec()
+
+	c.Next()
+
+	status := strconv.Itoa(c.Writer.Status()/100) + "xx"
+
+	metrics.requests.WithLabelValues(method, template, status).Inc()
+	metrics.latency.WithLabelValues(method, template, status).Observe(time.Since(started).Seconds())
+}
+
+//template returns the path of the route matching the request, e.g. `/users/:id`.
+//Routes are read on the first request, after every route is registered.
+func (table *routeTable) template(c *gin.Context) string {
+	table.once.Do(func() {
+		for _, info := range table.engine.Routes() {
+			table.routes = append(table.routes, route{
+				method:   info.Method,
+				path:     info.Path,
+				segments: strings.Split(info.Path, "/"),
+			})
+		}
+	})
+
+	segments := strings.Split(c.Request.URL.Path, "/")
+
+	for _, route := range table.routes {
+		if route.method == c.Request.Method && route.matches(segments, c.Params) {
+			return route.path
+		}
+	}
+
+	return UnmatchedRoute
+}
+
+func (route route) matches(segments []string, params gin.Params) bool {
+	for i, segment := range route.segments {
+		if strings.HasPrefix(segment, "*") {
+			value, _ := params.Get(segment[1:])
+			return value == "/"+strings.Join(segments[i:], "/")
+		}
+
+		if i >= len(segments) {
+			return false
+		}
+
+		if strings.HasPrefix(segment, ":") {
+			if value, _ := params.Get(segment[1:]); value != segments[i] {
+				return false
+			}
+			continue
+		}
+
+		if segment != segments[i] {
+			return false
+		}
+	}
+
+	return len(route.segments) == len(segments)
+}
+
+//mustRegister registers collector, reusing an identical one already registered.
+func mustRegister(registerer prometheus.Registerer, collector prometheus.Collector) prometheus.Collector {
+	if err := registerer.Register(collector); err != nil {
+		if registered, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return registered.ExistingCollector
+		}
+
+		panic(err)
+	}
+
+	return collector
+}
+
 func (server *Server) metrics() gin.HandlerFunc {
-	handler := promhttp.Handler()
+	handler := promhttp.InstrumentMetricHandler(
+		server.Settings.MetricsRegisterer,
+		promhttp.HandlerFor(server.Settings.MetricsGatherer, promhttp.HandlerOpts{}),
+	)
 	return func(c *gin.Context) {
 		handler.ServeHTTP(c.Writer, c.Request)
 	}
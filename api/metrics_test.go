@@ -0,0 +1,69 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+)
+
+func counters(t *testing.T, registry *prometheus.Registry, name string) map[string]float64 {
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+
+	values := make(map[string]float64)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			values[labels(metric)] = metric.GetCounter().GetValue()
+		}
+	}
+
+	return values
+}
+
+func labels(metric *dto.Metric) string {
+	var key string
+
+	for _, pair := range metric.GetLabel() {
+		key += pair.GetName() + "=" + pair.GetValue() + " "
+	}
+
+	return key
+}
+
+func TestRequestMetrics_LabelsByRouteTemplate(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	engine := gin.New()
+	engine.Use(api.RequestMetrics(engine, api.MetricsOptions{Registerer: registry}))
+	engine.GET("/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	engine.GET("/users/:id/files/*path", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+	engine.GET("/healthz/liveness", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/3/files/a/b.txt", "/nothing", "/healthz/liveness"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, map[string]float64{
+		"method=GET route=/users/:id status=2xx ":             2,
+		"method=GET route=/users/:id/files/*path status=4xx ": 1,
+		"method=GET route=unmatched status=4xx ":              1,
+	}, counters(t, registry, "grok_http_requests_total"))
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	auth0 "github.com/auth0-community/go-auth0"
+	"github.com/getmilly/grok/models"
+	"github.com/gin-gonic/gin"
+)
+
+//Policy checks if the request claims are allowed to proceed.
+//It returns nil when the access is granted.
+type Policy func(claims Claims) error
+
+var (
+	//ScopesClaims are the claims inspected by RequireScopes, in order.
+	ScopesClaims = []string{"scope", "scp", "scopes"}
+	//RolesClaims are the claims inspected by RequireRole, in order.
+	RolesClaims = []string{"roles", "role"}
+)
+
+//Authorization enforces all policies against the claims set by Authentication.
+func Authorization(policies ...Policy) gin.HandlerFunc {
+	policy := AllOf(policies...)
+
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFrom(c)
+
+		if !ok {
+			unauthorized(c, auth0.ErrTokenNotFound)
+			return
+		}
+
+		if err := policy(claims); err != nil {
+			message := forbidden(err)
+
+			c.Error(err)
+			c.Header("WWW-Authenticate", challenge(CodeInsufficientScope, message.Message))
+			c.AbortWithStatusJSON(http.StatusForbidden, message)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+//RequireScopes grants access when the token has every scope.
+func RequireScopes(scopes ...string) Policy {
+	return func(claims Claims) error {
+		granted := claims.Scopes()
+
+		for _, scope := range scopes {
+			if !contains(granted, scope) {
+				return forbidden(fmt.Errorf("Missing scope `%s`", scope))
+			}
+		}
+
+		return nil
+	}
+}
+
+//RequireRole grants access when the token has any of the roles.
+func RequireRole(roles ...string) Policy {
+	return func(claims Claims) error {
+		granted := claims.Roles()
+
+		for _, role := range roles {
+			if contains(granted, role) {
+				return nil
+			}
+		}
+
+		return forbidden(fmt.Errorf("Missing role `%s`", strings.Join(roles, "|")))
+	}
+}
+
+//RequireClaim grants access when the claim exists and satisfies the predicate.
+func RequireClaim(key string, predicate func(value interface{}) bool) Policy {
+	return func(claims Claims) error {
+		value, err := claims.GetKey(key)
+
+		if err != nil {
+			return forbidden(fmt.Errorf("Missing claim `%s`", key))
+		}
+
+		if predicate != nil && !predicate(value) {
+			return forbidden(fmt.Errorf("Claim `%s` not allowed", key))
+		}
+
+		return nil
+	}
+}
+
+//ClaimEquals is a predicate for RequireClaim matching an exact value.
+func ClaimEquals(expected interface{}) func(value interface{}) bool {
+	return func(value interface{}) bool {
+		return value == expected
+	}
+}
+
+//AllOf grants access when every policy grants it.
+func AllOf(policies ...Policy) Policy {
+	return func(claims Claims) error {
+		for _, policy := range policies {
+			if err := policy(claims); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+//AnyOf grants access when at least one policy grants it.
+func AnyOf(policies ...Policy) Policy {
+	return func(claims Claims) error {
+		var messages []string
+
+		for _, policy := range policies {
+			err := policy(claims)
+
+			if err == nil {
+				return nil
+			}
+
+			messages = append(messages, err.Error())
+		}
+
+		return forbidden(fmt.Errorf("%s", strings.Join(messages, " or ")))
+	}
+}
+
+func forbidden(err error) models.Error {
+	if message, ok := err.(models.Error); ok {
+		return message
+	}
+
+	return models.Error{
+		Code:           CodeInsufficientScope,
+		Message:        err.Error(),
+		HTTPStatusCode: http.StatusForbidden,
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
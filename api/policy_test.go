@@ -0,0 +1,55 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+)
+
+type staticAuth api.Claims
+
+func (claims staticAuth) Authorize(req *http.Request) (api.Claims, error) {
+	return api.Claims(claims), nil
+}
+
+func TestPolicies_Grant(t *testing.T) {
+	claims := api.Claims{
+		"sub":    "user",
+		"scope":  "orders:read orders:write",
+		"roles":  []interface{}{"support"},
+		"tenant": "acme",
+	}
+
+	assert.NoError(t, api.RequireScopes("orders:read", "orders:write")(claims))
+	assert.Error(t, api.RequireScopes("orders:read", "orders:delete")(claims))
+
+	assert.NoError(t, api.RequireRole("admin", "support")(claims))
+	assert.Error(t, api.RequireRole("admin")(claims))
+
+	assert.NoError(t, api.RequireClaim("tenant", api.ClaimEquals("acme"))(claims))
+	assert.Error(t, api.RequireClaim("tenant", api.ClaimEquals("other"))(claims))
+	assert.Error(t, api.RequireClaim("missing", nil)(claims))
+
+	assert.NoError(t, api.AllOf(api.RequireScopes("orders:read"), api.RequireRole("support"))(claims))
+	assert.Error(t, api.AllOf(api.RequireScopes("orders:read"), api.RequireRole("admin"))(claims))
+
+	assert.NoError(t, api.AnyOf(api.RequireRole("admin"), api.RequireScopes("orders:read"))(claims))
+	assert.Error(t, api.AnyOf(api.RequireRole("admin"), api.RequireScopes("orders:delete"))(claims))
+}
+
+func TestAuthorization_ForbidsMissingScope(t *testing.T) {
+	service := staticAuth{"sub": "user", "scp": []interface{}{"orders:read"}}
+
+	w, _ := serve(authRouter(service, api.RequireScopes("orders:read")), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "user", w.Body.String())
+
+	w, body := serve(authRouter(service, api.RequireScopes("orders:write")), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, api.CodeInsufficientScope, body.Code)
+	assert.Contains(t, body.Message, "orders:write")
+}
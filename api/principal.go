@@ -0,0 +1,184 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarulabs/di"
+)
+
+//PrincipalDef is the name of the request scoped principal in DI container.
+const PrincipalDef = "principal"
+
+var (
+	principalKey = "auth-principal"
+)
+
+//Principal is the authenticated identity of a request.
+type Principal struct {
+	Claims
+}
+
+//NewPrincipal creates a principal from validated claims.
+func NewPrincipal(claims Claims) *Principal {
+	return &Principal{Claims: claims}
+}
+
+//PrincipalFrom returns the principal set by Authentication in request scope.
+func PrincipalFrom(c *gin.Context) (*Principal, bool) {
+	value, ok := c.Get(principalKey)
+
+	if !ok {
+		return nil, false
+	}
+
+	principal, ok := value.(*Principal)
+
+	return principal, ok
+}
+
+//ClaimsFrom returns the claims set by Authentication in request scope.
+func ClaimsFrom(c *gin.Context) (Claims, bool) {
+	principal, ok := PrincipalFrom(c)
+
+	if !ok {
+		return nil, false
+	}
+
+	return principal.Claims, true
+}
+
+//Subject returns the `sub` claim.
+func (claims Claims) Subject() string {
+	return claims.GetString("sub")
+}
+
+//Issuer returns the `iss` claim.
+func (claims Claims) Issuer() string {
+	return claims.GetString("iss")
+}
+
+//Audience returns the `aud` claim.
+func (claims Claims) Audience() []string {
+	return claims.GetStringSlice("aud")
+}
+
+//Scopes returns the granted scopes looking up ScopesClaims.
+func (claims Claims) Scopes() []string {
+	return claims.lookupStrings(ScopesClaims)
+}
+
+//Roles returns the granted roles looking up RolesClaims.
+func (claims Claims) Roles() []string {
+	return claims.lookupStrings(RolesClaims)
+}
+
+//ExpiresAt returns the `exp` claim, zero time if not set.
+func (claims Claims) ExpiresAt() time.Time {
+	return claims.GetTime("exp")
+}
+
+//GetString returns a string claim, empty if missing or not a string.
+func (claims Claims) GetString(key string) string {
+	value, _ := claims[key].(string)
+	return value
+}
+
+//GetStringSlice returns a claim as a slice of strings.
+//A single string claim is returned as a one item slice.
+func (claims Claims) GetStringSlice(key string) []string {
+	switch v := claims[key].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		var values []string
+
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	}
+
+	return nil
+}
+
+//GetTime returns a NumericDate claim as time, zero time if missing.
+func (claims Claims) GetTime(key string) time.Time {
+	switch v := claims[key].(type) {
+	case float64:
+		return time.Unix(int64(v), 0)
+	case int64:
+		return time.Unix(v, 0)
+	case int:
+		return time.Unix(int64(v), 0)
+	case json.Number:
+		seconds, _ := v.Int64()
+		return time.Unix(seconds, 0)
+	}
+
+	return time.Time{}
+}
+
+//Decode fills v with the claims, using its json tags.
+func (claims Claims) Decode(v interface{}) error {
+	data, err := json.Marshal(claims)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (claims Claims) lookupStrings(keys []string) []string {
+	for _, key := range keys {
+		if _, ok := claims[key]; !ok {
+			continue
+		}
+
+		if value, ok := claims[key].(string); ok {
+			return strings.Fields(value)
+		}
+
+		return claims.GetStringSlice(key)
+	}
+
+	return nil
+}
+
+func principalDef() di.Def {
+	return di.Def{
+		Name:  PrincipalDef,
+		Scope: di.Request,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return &Principal{Claims: Claims{}}, nil
+		},
+	}
+}
+
+func setPrincipal(c *gin.Context, principal *Principal) {
+	c.Set(principalKey, principal)
+
+	container, err := Container(c)
+
+	if err != nil {
+		return
+	}
+
+	value, err := container.SafeGet(PrincipalDef)
+
+	if err != nil {
+		return
+	}
+
+	if target, ok := value.(*Principal); ok {
+		*target = *principal
+	}
+}
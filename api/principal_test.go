@@ -0,0 +1,68 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarulabs/di"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+)
+
+type accountController struct{}
+
+func (ctrl *accountController) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/me", func(c *gin.Context) {
+		container, err := api.Container(c)
+
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.String(http.StatusOK, container.Get(api.PrincipalDef).(*api.Principal).Subject())
+	})
+}
+
+func TestClaims_Accessors(t *testing.T) {
+	claims := api.Claims{
+		"sub":   "user",
+		"iss":   "issuer",
+		"aud":   "audience",
+		"scope": "orders:read orders:write",
+		"roles": []interface{}{"admin", 1},
+		"exp":   float64(1700000000),
+	}
+
+	assert.Equal(t, "user", claims.Subject())
+	assert.Equal(t, "issuer", claims.Issuer())
+	assert.Equal(t, []string{"audience"}, claims.Audience())
+	assert.Equal(t, []string{"orders:read", "orders:write"}, claims.Scopes())
+	assert.Equal(t, []string{"admin"}, claims.Roles())
+	assert.Equal(t, time.Unix(1700000000, 0), claims.ExpiresAt())
+
+	var decoded struct {
+		Subject string `json:"sub"`
+	}
+
+	assert.NoError(t, claims.Decode(&decoded))
+	assert.Equal(t, "user", decoded.Subject)
+}
+
+func TestServer_ResolvesPrincipalFromContainer(t *testing.T) {
+	ts := authorizedServer(t, &api.Settings{}, di.Def{
+		Name: "account-controller",
+		Build: func(ctn di.Container) (interface{}, error) {
+			return &accountController{}, nil
+		},
+	})
+	defer ts.Close()
+
+	status, body := bearerGet(t, ts.URL+"/me", hs256Token(t, []byte(testSecret)))
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "service", body)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//DefaultPublicPaths are the built-in endpoints reachable without authentication.
+//`/metrics` and `/log/level` are protected by the metrics credentials instead, see Settings.MetricsToken.
+var DefaultPublicPaths = []string{"/healthz/*", "/metrics", "/log/level", "/swagger/*"}
+
+//PublicController is implemented by controllers that expose anonymous routes.
+//Routes registered in RegisterPublicRoutes bypass authentication.
+type PublicController interface {
+	RegisterPublicRoutes(router *gin.RouterGroup)
+}
+
+//SkipPaths runs the handler only for requests outside the given paths.
+//Paths are relative to basePath and a trailing `*` matches any suffix.
+func SkipPaths(basePath string, paths []string, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if matchPath(paths, strings.TrimPrefix(c.Request.URL.Path, strings.TrimSuffix(basePath, "/"))) {
+			c.Next()
+			return
+		}
+
+		handler(c)
+	}
+}
+
+func matchPath(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+			continue
+		}
+
+		if path == pattern {
+			return true
+		}
+	}
+
+	return false
+}
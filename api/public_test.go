@@ -0,0 +1,100 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarulabs/di"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+)
+
+const testSecret = "a-very-long-shared-secret-value!"
+
+type catalogController struct{}
+
+func (ctrl *catalogController) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/orders", func(c *gin.Context) {
+		c.String(http.StatusOK, "orders")
+	})
+	router.GET("/status/live", func(c *gin.Context) {
+		c.String(http.StatusOK, "live")
+	})
+}
+
+func (ctrl *catalogController) RegisterPublicRoutes(router *gin.RouterGroup) {
+	router.GET("/catalog", func(c *gin.Context) {
+		_, authenticated := api.PrincipalFrom(c)
+		c.JSON(http.StatusOK, authenticated)
+	})
+}
+
+func authorizedServer(t *testing.T, settings *api.Settings, def di.Def) *httptest.Server {
+	settings.Host = "127.0.0.1:0"
+	settings.Authorize = true
+
+	if len(settings.Authorization.Modes) == 0 {
+		settings.Authorization.Modes = []string{api.AuthModeHS256}
+	}
+
+	settings.Authorization.Secret = testSecret
+	settings.Authorization.Issuer = "issuer"
+	settings.Authorization.Audience = []string{"audience"}
+
+	server := api.ConfigureServer(func() *api.Settings {
+		return settings
+	}, api.DefaultHealthChecks())
+
+	assert.NoError(t, server.AddController(def))
+
+	return httptest.NewServer(server.Handler())
+}
+
+func bearerGet(t *testing.T, url, token string) (int, string) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	return resp.StatusCode, string(body)
+}
+
+func TestServer_PublicRoutesBypassAuthentication(t *testing.T) {
+	ts := authorizedServer(t, &api.Settings{
+		BasePath:    "/v1",
+		PublicPaths: append([]string{"/status/*"}, api.DefaultPublicPaths...),
+	}, di.Def{
+		Name:  "catalog-controller",
+		Scope: di.App,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return &catalogController{}, nil
+		},
+	})
+	defer ts.Close()
+
+	status, _ := bearerGet(t, ts.URL+"/v1/orders", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, body := bearerGet(t, ts.URL+"/v1/orders", hs256Token(t, []byte(testSecret)))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "orders", body)
+
+	status, body = bearerGet(t, ts.URL+"/v1/catalog", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "false", body)
+
+	status, body = bearerGet(t, ts.URL+"/v1/status/live", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "live", body)
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/getmilly/grok/config"
+	"github.com/getmilly/grok/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//reloadableAuth delegates to an AuthService replaced on reload.
+type reloadableAuth struct {
+	mu         sync.RWMutex
+	service    AuthService
+	settings   AuthorizationSettings
+	collectors []prometheus.Collector
+}
+
+//Reload applies the settings that can change without a restart:
+//AllowedOrigins, LogLevel and Authorization, e.g. audiences.
+//Other fields, like Host or Authorize, only take effect on the next start.
+//Nothing is applied if any of them is invalid.
+func (server *Server) Reload(settings *Settings) error {
+	if settings.LogLevel != "" {
+		if _, err := logging.ParseLevel(settings.LogLevel); err != nil {
+			return err
+		}
+	}
+
+	swapAuth := func() {}
+
+	if server.auth != nil {
+		swap, err := server.auth.prepare(settings.Authorization)
+
+		if err != nil {
+			return err
+		}
+
+		swapAuth = swap
+	}
+
+	swapAuth()
+
+	if settings.LogLevel != "" {
+		server.Logger.SetLevel(settings.LogLevel)
+	}
+
+	server.origins.Store(settings.AllowedOrigins)
+
+	return nil
+}
+
+//WatchSettings reloads the server whenever the store reloads.
+//The store must hold *Settings or a struct embedding Settings.
+func (server *Server) WatchSettings(store *config.Store) {
+	store.Subscribe(func(settings interface{}) {
+		if err := server.Reload(settings.(settingsProvider).APISettings()); err != nil {
+			logging.LogWith(err).Error("server reload error")
+		}
+	})
+}
+
+func (server *Server) cors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, _ := server.origins.Load().([]string)
+
+		switch {
+		case len(allowed) == 0:
+			c.Next()
+		case contains(allowed, "*"):
+			cors(c, nil)
+		default:
+			cors(c, allowed)
+		}
+	}
+}
+
+func newReloadableAuth(settings AuthorizationSettings) (*reloadableAuth, error) {
+	service, collectors, err := newAuthService(settings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &reloadableAuth{}
+	auth.swap(service, settings, collectors)
+
+	return auth, nil
+}
+
+func (auth *reloadableAuth) Authorize(req *http.Request) (Claims, error) {
+	auth.mu.RLock()
+	service := auth.service
+	auth.mu.RUnlock()
+
+	return service.Authorize(req)
+}
+
+//prepare builds the service for settings, returning the function that swaps it in.
+func (auth *reloadableAuth) prepare(settings AuthorizationSettings) (func(), error) {
+	auth.mu.RLock()
+	current := auth.settings
+	auth.mu.RUnlock()
+
+	if settings.Services == nil {
+		settings.Services = current.Services
+	}
+
+	if settings.Registerer == nil {
+		settings.Registerer = current.Registerer
+	}
+
+	if reflect.DeepEqual(settings, current) {
+		return func() {}, nil
+	}
+
+	service, collectors, err := newAuthService(settings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		auth.swap(service, settings, collectors)
+		logging.LogInfo("authorization settings reloaded")
+	}, nil
+}
+
+//swap replaces the service and its collectors, e.g. the JWKS cache metrics.
+func (auth *reloadableAuth) swap(service AuthService, settings AuthorizationSettings, collectors []prometheus.Collector) {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+
+	for _, collector := range auth.collectors {
+		auth.settings.registerer().Unregister(collector)
+	}
+
+	for _, collector := range collectors {
+		if err := replaceCollector(settings.registerer(), collector); err != nil {
+			logging.LogWith(err).Error("auth metrics registration error")
+		}
+	}
+
+	auth.service = service
+	auth.settings = settings
+	auth.collectors = collectors
+}
@@ -1,69 +1,237 @@
 package api
 
 import (
+	"fmt"
 	"os"
-	"strconv"
 	"strings"
+	"time"
 
+	"github.com/getmilly/grok/config"
+	"github.com/getmilly/grok/logging"
+	"github.com/getmilly/grok/tracing"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 //Settings stores some configs about how the API will woks.
+//Fields are loaded by config.Load, see LoadSettings.
 type Settings struct {
-	Host          string
-	Authorize     bool
-	Authorization struct {
-		JwksURI  string
-		Issuer   string
-		Audience []string
-	}
-	BasePath        string
-	ApplicationName string
-	SwaggerPath     string
+	Host            string `env:"HOST" required:"true"`
+	Authorize       bool   `env:"AUTHORIZE" default:"true"`
+	Authorization   AuthorizationSettings
+	BasePath        string `env:"BASE_PATH"`
+	ApplicationName string `env:"APPLICATION_NAME"`
+	SwaggerPath     string `env:"SWAGGER_PATH"`
+	//LogLevel is the minimum level logged, reloadable and changed live by PUT /log/level on AdminHost.
+	LogLevel string `env:"LOG_LEVEL" default:"debug"`
+	//LogRedactHeaders and LogRedactPaths are masked in logs, besides logging.DefaultRedactionOptions.
+	LogRedactHeaders []string `env:"LOG_REDACT_HEADERS"`
+	LogRedactPaths   []string `env:"LOG_REDACT_PATHS"`
+	//AccessLogSkipPaths aren't logged by the access log, see AccessLogOptions.
+	AccessLogSkipPaths []string `env:"ACCESS_LOG_SKIP_PATHS"`
+	//AccessLogMaxBodySize in bytes of bodies logged, 4096 when zero, negative disables body logging.
+	AccessLogMaxBodySize int `env:"ACCESS_LOG_MAX_BODY_SIZE"`
+	//AccessLogSampleRate of requests answered below 400 logged, from 0 to 1, 1 when zero.
+	AccessLogSampleRate float64 `env:"ACCESS_LOG_SAMPLE_RATE"`
+	//AccessLogSlowThreshold always logs slower requests, as warnings.
+	AccessLogSlowThreshold time.Duration `env:"ACCESS_LOG_SLOW_THRESHOLD"`
+	//AccessLogRedactedParams are query and form parameters masked by the access log, nil means DefaultRedactedQueryParams.
+	AccessLogRedactedParams []string `env:"ACCESS_LOG_REDACTED_PARAMS"`
+	//AllowedOrigins enables CORS for these origins, `*` allows any, reloadable.
+	AllowedOrigins []string `env:"ALLOWED_ORIGINS"`
+	//PublicPaths bypass authentication, nil means DefaultPublicPaths.
+	PublicPaths []string `env:"PUBLIC_PATHS"`
+	//ShutdownTimeout bounds each shutdown stage.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"5s"`
+	//ShutdownDrainDelay is how long readiness fails before draining.
+	ShutdownDrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY"`
+	//WarmupTimeout bounds warm-up hooks, zero means no limit.
+	WarmupTimeout time.Duration `env:"WARMUP_TIMEOUT" default:"5m"`
+
+	TLS TLSSettings
+	//H2C serves HTTP/2 without TLS, for internal traffic.
+	H2C               bool          `env:"H2C"`
+	ReadTimeout       time.Duration `env:"READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `env:"READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `env:"WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `env:"IDLE_TIMEOUT"`
+	MaxHeaderBytes    int           `env:"MAX_HEADER_BYTES"`
+
+	//MetricsBuckets are the request latency histogram buckets in seconds.
+	MetricsBuckets []float64 `env:"METRICS_BUCKETS"`
+	//MetricsSkipPaths aren't measured, nil means DefaultMetricsSkipPaths.
+	MetricsSkipPaths []string `env:"METRICS_SKIP_PATHS"`
+	//AdminHost serves /metrics, /log/level and /healthz/* on a separate listener, e.g. `:9090`.
+	//PUT /log/level is only available there.
+	AdminHost string `env:"ADMIN_HOST"`
+	//MetricsUsername and MetricsPassword protect /metrics and /log/level with basic auth.
+	MetricsUsername string `env:"METRICS_USERNAME"`
+	MetricsPassword string `env:"METRICS_PASSWORD" secret:"true"`
+	//MetricsToken protects /metrics and /log/level with a bearer token.
+	MetricsToken string `env:"METRICS_TOKEN" secret:"true"`
+	//MetricsRegisterer registers framework collectors, prometheus.DefaultRegisterer when nil.
+	MetricsRegisterer prometheus.Registerer `json:"-" yaml:"-"`
+	//MetricsGatherer is served by /metrics, MetricsRegisterer when it's also a Gatherer.
+	MetricsGatherer prometheus.Gatherer `json:"-" yaml:"-"`
+
+	//OTLPEndpoint exports traces to an OpenTelemetry collector, e.g. `http://collector:4318`.
+	OTLPEndpoint string `env:"OTLP_ENDPOINT"`
+	//TraceSampleRatio of new traces recorded, from 0 to 1, 1 when zero.
+	TraceSampleRatio float64 `env:"TRACE_SAMPLE_RATIO"`
+	//Tracer replaces the one built from OTLPEndpoint, e.g. with an in-memory exporter.
+	Tracer *tracing.Tracer `json:"-" yaml:"-"`
+	//Logger replaces logging.Default() and becomes the default, e.g. to log elsewhere than stdout.
+	//It is closed on shutdown, after every other hook, flushing async and file sinks.
+	Logger *logging.Logger `json:"-" yaml:"-"`
+}
+
+//AuthorizationSettings configures how tokens are validated.
+type AuthorizationSettings struct {
+	//Modes are the validators tried in order: jwks, hs256, pem, introspection, apikey or mtls.
+	Modes    []string `env:"AUTH_MODE" default:"jwks"`
+	JwksURI  string   `env:"JWKS_URI"`
+	Issuer   string   `env:"ISSUER,ISSUSER"`
+	Audience []string `env:"AUDIENCE"`
+	//Secret is the HS256 shared secret.
+	Secret string `env:"AUTH_SECRET" secret:"true"`
+	//PublicKeyFile is a PEM encoded public key or certificate.
+	PublicKeyFile string `env:"AUTH_PUBLIC_KEY_FILE"`
+	//IntrospectionURI is an RFC 7662 token introspection endpoint.
+	IntrospectionURI          string `env:"INTROSPECTION_URI"`
+	IntrospectionClientID     string `env:"INTROSPECTION_CLIENT_ID"`
+	IntrospectionClientSecret string `env:"INTROSPECTION_CLIENT_SECRET" secret:"true"`
+	//IntrospectionCacheTTL caps how long active tokens are cached, DefaultIntrospectionCacheTTL when zero.
+	IntrospectionCacheTTL time.Duration `env:"INTROSPECTION_CACHE_TTL"`
+	//APIKeysFile is a dotenv file read by the apikey mode.
+	APIKeysFile string `env:"API_KEYS_FILE"`
+	//Services are custom auth services tried after the configured modes.
+	Services []AuthService `json:"-" yaml:"-"`
+	//Registerer registers the JWKS cache metrics, prometheus.DefaultRegisterer when nil.
+	Registerer prometheus.Registerer `json:"-" yaml:"-"`
 }
 
 //SettingGenerator creates a instance of Settings.
 type SettingGenerator func() *Settings
 
 //SettingsFromDotEnv generates settings using environment variables.
+//Variables in files are exported to the process, `.env` is optional.
+//It panics listing every invalid setting.
 func SettingsFromDotEnv(files ...string) SettingGenerator {
 	return func() *Settings {
 		err := godotenv.Load(files...)
 
-		if err != nil {
+		if err != nil && !(len(files) == 0 && os.IsNotExist(err)) {
 			panic(err)
 		}
 
-		authorize, err := strconv.ParseBool(os.Getenv("AUTHORIZE"))
+		settings, err := LoadSettings()
 
 		if err != nil {
-			authorize = true
+			panic(err)
 		}
 
-		authorization := struct {
-			JwksURI  string
-			Issuer   string
-			Audience []string
-		}{
-			Audience: strings.Split(os.Getenv("AUDIENCE"), ","),
-			Issuer:   os.Getenv("ISSUSER"),
-			JwksURI:  os.Getenv("JWKS_URI"),
-		}
+		return settings
+	}
+}
+
+//LoadSettings loads settings with config.Load and the given sources.
+func LoadSettings(options ...config.Option) (*Settings, error) {
+	settings := &Settings{}
+
+	if _, err := config.Load(settings, options...); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+//SettingsFromStore uses the current settings of a config.Store.
+//The store must hold *Settings or a struct embedding Settings.
+func SettingsFromStore(store *config.Store) SettingGenerator {
+	return func() *Settings {
+		return store.Current().(settingsProvider).APISettings()
+	}
+}
+
+//APISettings returns settings itself, it's promoted to structs embedding Settings.
+func (settings *Settings) APISettings() *Settings {
+	return settings
+}
+
+type settingsProvider interface {
+	APISettings() *Settings
+}
+
+//Validate checks the log level, redaction paths and settings required by the enabled authorization modes.
+func (settings *Settings) Validate() error {
+	var problems []string
+
+	if settings.LogLevel != "" && !logging.ValidLevel(settings.LogLevel) {
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL: unknown level `%s`", settings.LogLevel))
+	}
 
-		host := os.Getenv("HOST")
-		basePath := os.Getenv("BASE_PATH")
-		appName := os.Getenv("APPLICATION_NAME")
-		swaggerPath := os.Getenv("SWAGGER_PATH")
+	if _, err := logging.NewRedactor(settings.redactionOptions()); err != nil {
+		problems = append(problems, fmt.Sprintf("LOG_REDACT_PATHS: %s", err))
+	}
 
-		//TODO: validade required envs.
+	for _, mode := range settings.authModes() {
+		switch strings.TrimSpace(strings.ToLower(mode)) {
+		case AuthModeJWKS:
+			problems = appendMissing(problems, "JWKS_URI", settings.Authorization.JwksURI)
+		case AuthModeHS256:
+			problems = appendMissing(problems, "AUTH_SECRET", settings.Authorization.Secret)
 
-		return &Settings{
-			Host:            host,
-			BasePath:        basePath,
-			Authorize:       authorize,
-			Authorization:   authorization,
-			ApplicationName: appName,
-			SwaggerPath:     swaggerPath,
+			if secret := settings.Authorization.Secret; secret != "" && len(secret) < MinSecretSize {
+				problems = append(problems, fmt.Sprintf("AUTH_SECRET: must have at least %d bytes", MinSecretSize))
+			}
+		case AuthModePEM:
+			problems = appendMissing(problems, "AUTH_PUBLIC_KEY_FILE", settings.Authorization.PublicKeyFile)
+		case AuthModeIntrospection:
+			problems = appendMissing(problems, "INTROSPECTION_URI", settings.Authorization.IntrospectionURI)
+		case AuthModeAPIKey:
+			problems = appendMissing(problems, "API_KEYS_FILE", settings.Authorization.APIKeysFile)
+		case AuthModeMTLS:
+			problems = appendMissing(problems, "TLS_CLIENT_CA_FILE", settings.TLS.ClientCAFile)
+		default:
+			problems = append(problems, fmt.Sprintf("AUTH_MODE: unknown mode `%s`", mode))
 		}
 	}
+
+	if len(problems) > 0 {
+		return &config.Error{Problems: problems}
+	}
+
+	return nil
+}
+
+func (settings AuthorizationSettings) registerer() prometheus.Registerer {
+	if settings.Registerer == nil {
+		return prometheus.DefaultRegisterer
+	}
+
+	return settings.Registerer
+}
+
+func (settings *Settings) redactionOptions() logging.RedactionOptions {
+	options := logging.DefaultRedactionOptions()
+	options.Headers = append(append([]string{}, options.Headers...), settings.LogRedactHeaders...)
+	options.Paths = append(append([]string{}, options.Paths...), settings.LogRedactPaths...)
+
+	return options
+}
+
+func (settings *Settings) authModes() []string {
+	if !settings.Authorize {
+		return nil
+	}
+
+	return settings.Authorization.Modes
+}
+
+func appendMissing(problems []string, name, value string) []string {
+	if value == "" {
+		return append(problems, fmt.Sprintf("%s is required by AUTH_MODE", name))
+	}
+
+	return problems
 }
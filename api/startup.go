@@ -3,17 +3,20 @@ package api
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
-	"time"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
+	"github.com/getmilly/grok/lifecycle"
 	"github.com/getmilly/grok/logging"
+	"github.com/getmilly/grok/tracing"
 	"github.com/sarulabs/di"
 	"github.com/swaggo/gin-swagger"
 	"github.com/swaggo/gin-swagger/swaggerFiles"
-	"github.com/swaggo/swag"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 //Server wraps API configurations.
@@ -24,10 +27,23 @@ type Server struct {
 	DIBuilder *di.Builder
 	Container di.Container
 
-	Healthz *HealthChecks
+	Healthz   *HealthChecks
+	Lifecycle *lifecycle.Manager
+	Tracer    *tracing.Tracer
+	Logger    *logging.Logger
 
 	router      *gin.RouterGroup
+	public      *gin.RouterGroup
 	controllers []string
+	prepare     sync.Once
+	srv         *http.Server
+	listener    net.Listener
+	auth        *reloadableAuth
+	origins     atomic.Value
+	warmup      warmup
+
+	admin         *gin.Engine
+	adminListener net.Listener
 }
 
 var (
@@ -40,8 +56,10 @@ func ConfigureServer(generator SettingGenerator, healthz *HealthChecks) *Server
 	server := &Server{}
 	server.Settings = generator()
 	server.Healthz = healthz
-
-	logging.LogWithApplication(server.Settings.ApplicationName)
+	server.Lifecycle = lifecycle.New(lifecycle.Options{
+		DrainDelay:   server.Settings.ShutdownDrainDelay,
+		StageTimeout: server.Settings.ShutdownTimeout,
+	})
 
 	builder, err := di.NewBuilder()
 
@@ -50,36 +68,75 @@ func ConfigureServer(generator SettingGenerator, healthz *HealthChecks) *Server
 	}
 
 	server.DIBuilder = builder
+
+	if err := server.DIBuilder.Add(principalDef()); err != nil {
+		panic(err)
+	}
+
+	if err := server.DIBuilder.Add(spanDef()); err != nil {
+		panic(err)
+	}
+
+	server.configureLogger()
+	server.configureMetricsRegistry()
+	server.configureTracing()
+
 	server.Engine = gin.New()
-	server.Engine.Use(Logging())
+	server.Engine.Use(AccessLog(AccessLogOptions{
+		BasePath:            server.Settings.BasePath,
+		SkipPaths:           server.Settings.AccessLogSkipPaths,
+		MaxBodySize:         server.Settings.AccessLogMaxBodySize,
+		SampleRate:          server.Settings.AccessLogSampleRate,
+		SlowThreshold:       server.Settings.AccessLogSlowThreshold,
+		RedactedQueryParams: server.Settings.AccessLogRedactedParams,
+	}))
+	server.Engine.Use(Tracing(server.Engine, server.Tracer))
+	server.Engine.Use(RequestMetrics(server.Engine, MetricsOptions{
+		Buckets:    server.Settings.MetricsBuckets,
+		BasePath:   server.Settings.BasePath,
+		SkipPaths:  server.Settings.MetricsSkipPaths,
+		Registerer: server.Settings.MetricsRegisterer,
+	}))
 	server.Engine.Use(gin.Recovery())
+	server.Engine.Use(server.cors())
+
+	server.origins.Store(server.Settings.AllowedOrigins)
 
 	server.Engine.NoRoute(func(c *gin.Context) {
 		c.AbortWithStatus(http.StatusNotFound)
 	})
 
 	server.router = server.Engine.Group(server.Settings.BasePath)
+	server.public = server.Engine.Group(server.Settings.BasePath)
 
 	server.router.Use(server.containerHandler())
-	server.router.GET("/metrics", server.metrics())
-	server.router.GET("/healthz/liveness", server.liveness())
-	server.router.GET("/healthz/readiness", server.readiness())
-
-	doc := NewSwaggerDoc(server.Settings.SwaggerPath)
-	swag.Register(swag.Name, doc)
+	server.public.Use(server.containerHandler())
 
-	server.router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	if server.Settings.PublicPaths == nil {
+		server.Settings.PublicPaths = DefaultPublicPaths
+	}
 
 	if server.Settings.Authorize {
-		server.router.Use(Authentication(
-			NewAuthService(
-				server.Settings.Authorization.JwksURI,
-				server.Settings.Authorization.Issuer,
-				server.Settings.Authorization.Audience,
-			),
+		auth, err := newReloadableAuth(server.Settings.Authorization)
+
+		if err != nil {
+			panic(err)
+		}
+
+		server.auth = auth
+		server.router.Use(SkipPaths(
+			server.Settings.BasePath,
+			server.Settings.PublicPaths,
+			Authentication(auth),
 		))
 	}
 
+	server.registerAdminRoutes()
+
+	registerSwagger(server.Settings.SwaggerPath)
+
+	server.router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
 	return server
 }
 
@@ -94,38 +151,129 @@ func (server *Server) AddController(def di.Def) error {
 	return server.DIBuilder.Add(def)
 }
 
-//Run starts the server.
-func (server *Server) Run() {
-	server.Container = server.DIBuilder.Build()
+//Run starts the server and blocks until it's shut down.
+//It returns the warm-up error if warm-up failed, the process should then exit non-zero.
+func (server *Server) Run() error {
+	if err := server.Start(context.Background()); err != nil {
+		return err
+	}
+
+	err := server.Lifecycle.Wait()
 
-	for _, ctrl := range server.extractControllers() {
-		ctrl.RegisterRoutes(server.router)
+	if warmupErr := server.warmupErr(); warmupErr != nil {
+		return warmupErr
 	}
 
-	srv := http.Server{
-		Addr:    server.Settings.Host,
-		Handler: server.Engine,
+	return err
+}
+
+//Start listens on Settings.Host and serves in background, then runs warm-up hooks.
+//Use `:0` to listen on an ephemeral port, see Addr.
+func (server *Server) Start(ctx context.Context) error {
+	handler := server.Handler()
+
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", server.Settings.Host)
+
+	if err != nil {
+		logging.LogWith(err).Error("startup error")
+		return err
 	}
 
-	sigs := make(chan os.Signal)
-	signal.Notify(sigs, os.Interrupt)
+	tlsConfig, err := NewTLSConfig(server.Settings.TLS)
 
-	go func() {
-		sig := <-sigs
-		logging.LogInfo("caught sig: %+v", sig)
-		logging.LogInfo("waiting 5 seconds to finish processing")
+	if err != nil {
+		listener.Close()
+		logging.LogWith(err).Error("tls error")
+		return err
+	}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+	if tlsConfig == nil && server.Settings.H2C {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	if err := server.startAdmin(ctx); err != nil {
+		listener.Close()
+		return err
+	}
+
+	server.listener = listener
+	server.srv = &http.Server{
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadTimeout:       server.Settings.ReadTimeout,
+		ReadHeaderTimeout: server.Settings.ReadHeaderTimeout,
+		WriteTimeout:      server.Settings.WriteTimeout,
+		IdleTimeout:       server.Settings.IdleTimeout,
+		MaxHeaderBytes:    server.Settings.MaxHeaderBytes,
+	}
+
+	server.Lifecycle.Register(lifecycle.StageServers, "http server", server.srv.Shutdown)
 
-		if err := srv.Shutdown(ctx); err != nil {
-			logging.LogWith(err).Error("shotdown error")
+	go func() {
+		if err := server.serve(); err != nil && err != http.ErrServerClosed {
+			logging.LogWith(err).Error("serve error")
+			server.Lifecycle.Fail(err)
 		}
 	}()
 
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		logging.LogWith(err).Info("startup error")
+	go server.runWarmup()
+
+	return nil
+}
+
+func (server *Server) serve() error {
+	if server.srv.TLSConfig != nil {
+		return server.srv.ServeTLS(server.listener, "", "")
 	}
+
+	return server.srv.Serve(server.listener)
+}
+
+//Stop gracefully shuts the server down, running every lifecycle hook.
+func (server *Server) Stop(ctx context.Context) error {
+	done := make(chan error, 1)
+
+	go func() {
+		done <- server.Lifecycle.Shutdown()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//Addr returns the address the server listens on.
+func (server *Server) Addr() string {
+	if server.listener == nil {
+		return server.Settings.Host
+	}
+
+	return server.listener.Addr().String()
+}
+
+//Handler builds the DI container and registers controllers without listening.
+//It's safe to call many times, e.g. with httptest.
+func (server *Server) Handler() http.Handler {
+	server.prepare.Do(func() {
+		server.Container = server.DIBuilder.Build()
+
+		for _, ctrl := range server.extractControllers() {
+			ctrl.RegisterRoutes(server.router)
+
+			if public, ok := ctrl.(PublicController); ok {
+				public.RegisterPublicRoutes(server.public)
+			}
+		}
+
+		server.Lifecycle.OnShutdown("di container", func(ctx context.Context) error {
+			return server.Container.Delete()
+		})
+	})
+
+	return server.Engine
 }
 
 //Container return DI Container defined in request scope.
@@ -156,6 +304,7 @@ func (server *Server) containerHandler() gin.HandlerFunc {
 
 			defer container.Delete()
 			c.Set(containerKey, container)
+			setSpan(container, tracing.SpanFromContext(c.Request.Context()))
 		}
 		c.Next()
 	}
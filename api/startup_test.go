@@ -0,0 +1,186 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sarulabs/di"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+	"github.com/getmilly/grok/logging"
+)
+
+type pingController struct{}
+
+func (ctrl *pingController) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+}
+
+func testServer(t *testing.T) *api.Server {
+	server := api.ConfigureServer(func() *api.Settings {
+		return &api.Settings{Host: "127.0.0.1:0"}
+	}, api.DefaultHealthChecks())
+
+	err := server.AddController(di.Def{
+		Name:  "ping-controller",
+		Scope: di.App,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return &pingController{}, nil
+		},
+	})
+	assert.NoError(t, err)
+
+	return server
+}
+
+func get(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	return resp.StatusCode, string(body)
+}
+
+func TestServer_Handler(t *testing.T) {
+	server := testServer(t)
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	status, body := get(t, ts.URL+"/ping")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "pong", body)
+
+	status, _ = get(t, ts.URL+"/healthz/liveness")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestServer_StartStop(t *testing.T) {
+	server := testServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, server.Start(ctx))
+
+	status, body := get(t, "http://"+server.Addr()+"/ping")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "pong", body)
+
+	assert.NoError(t, server.Stop(ctx))
+
+	_, err := http.Get("http://" + server.Addr() + "/ping")
+	assert.Error(t, err)
+}
+
+func TestServer_StopFlushesLogger(t *testing.T) {
+	defer logging.SetDefault(logging.Default())
+
+	out := &bytes.Buffer{}
+
+	logger, err := logging.New(logging.Config{Level: "info", Sinks: []io.Writer{logging.Async(out, 1024)}})
+	assert.NoError(t, err)
+
+	server := api.ConfigureServer(func() *api.Settings {
+		return &api.Settings{Host: "127.0.0.1:0", Logger: logger, MetricsRegisterer: prometheus.NewRegistry()}
+	}, api.DefaultHealthChecks())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, server.Start(ctx))
+	assert.NoError(t, server.Stop(ctx))
+
+	assert.Contains(t, out.String(), "shutting down http server")
+	assert.Contains(t, out.String(), "shutting down logger")
+}
+
+func TestServer_ReloadCORSOrigins(t *testing.T) {
+	server := testServer(t)
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	origin := func() string {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/ping", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.Header.Get("Access-Control-Allow-Origin")
+	}
+
+	assert.Empty(t, origin())
+
+	assert.NoError(t, server.Reload(&api.Settings{
+		Host:           "127.0.0.1:0",
+		LogLevel:       "info",
+		AllowedOrigins: []string{"https://app.example.com"},
+	}))
+	assert.Equal(t, "https://app.example.com", origin())
+
+	assert.Error(t, server.Reload(&api.Settings{LogLevel: "loud"}))
+}
+
+func TestServer_ReloadAppliesNothingWhenInvalid(t *testing.T) {
+	logger, err := logging.New(logging.Config{Level: "info", Sinks: []io.Writer{ioutil.Discard}})
+	assert.NoError(t, err)
+
+	settings := &api.Settings{Host: "127.0.0.1:0", Authorize: true, Logger: logger}
+	settings.Authorization.Modes = []string{api.AuthModeHS256}
+	settings.Authorization.Secret = testSecret
+
+	server := api.ConfigureServer(func() *api.Settings {
+		return settings
+	}, api.DefaultHealthChecks())
+
+	invalid := &api.Settings{LogLevel: "trace", AllowedOrigins: []string{"*"}}
+	invalid.Authorization.Modes = []string{api.AuthModeHS256}
+	invalid.Authorization.Secret = "short"
+
+	assert.Equal(t, api.ErrWeakSecret, server.Reload(invalid))
+	assert.Equal(t, logging.InfoLevel, server.Logger.Level())
+}
+
+func TestServer_ReloadReplacesJWKSMetrics(t *testing.T) {
+	first := newJWKSServer(t)
+	defer first.Close()
+
+	second := newJWKSServer(t)
+	defer second.Close()
+
+	registry := prometheus.NewRegistry()
+	settings := &api.Settings{Host: "127.0.0.1:0", Authorize: true, MetricsRegisterer: registry}
+	settings.Authorization.JwksURI = first.URL
+
+	server := api.ConfigureServer(func() *api.Settings {
+		return settings
+	}, api.DefaultHealthChecks())
+
+	assert.Contains(t, counters(t, registry, "grok_jwks_cache_total"), "result=hit uri="+first.URL+" ")
+
+	reloaded := &api.Settings{}
+	reloaded.Authorization.JwksURI = second.URL
+
+	assert.NoError(t, server.Reload(reloaded))
+
+	metrics := counters(t, registry, "grok_jwks_cache_total")
+
+	assert.Contains(t, metrics, "result=hit uri="+second.URL+" ")
+	assert.NotContains(t, metrics, "result=hit uri="+first.URL+" ")
+}
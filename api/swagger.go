@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"sync"
+
+	"github.com/swaggo/swag"
 )
 
 var (
-	swagger string
+	swagger     string
+	swaggerOnce sync.Once
 )
 
 //SwaggerDoc ...
@@ -43,3 +47,9 @@ func (s *SwaggerDoc) ReadDoc() string {
 
 	return swagger
 }
+
+func registerSwagger(path string) {
+	swaggerOnce.Do(func() {
+		swag.Register(swag.Name, NewSwaggerDoc(path))
+	})
+}
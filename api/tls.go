@@ -0,0 +1,202 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/getmilly/grok/logging"
+)
+
+//AuthModeMTLS authenticates requests by their verified client certificate.
+const AuthModeMTLS = "mtls"
+
+var (
+	//ErrClientCertMissing is returned when the request has no verified client certificate.
+	ErrClientCertMissing = errors.New("Client certificate missing")
+)
+
+//TLSSettings configures HTTPS serving.
+type TLSSettings struct {
+	CertFile string `env:"TLS_CERT_FILE"`
+	KeyFile  string `env:"TLS_KEY_FILE"`
+	//ClientCAFile enables mutual TLS with the given CA bundle.
+	ClientCAFile string `env:"TLS_CLIENT_CA_FILE"`
+	//RequireClientCert rejects handshakes without a valid client certificate.
+	RequireClientCert bool `env:"TLS_REQUIRE_CLIENT_CERT"`
+}
+
+//Enabled reports whether a certificate is configured.
+func (settings TLSSettings) Enabled() bool {
+	return settings.CertFile != "" && settings.KeyFile != ""
+}
+
+//CertReloader serves a key pair, reloading it when the files change.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+type clientCertAuth struct{}
+
+//NewCertReloader loads a key pair and checks the files for changes at most every 10 seconds.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	reloader := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: 10 * time.Second,
+	}
+
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+//GetCertificate implements tls.Config.GetCertificate.
+func (reloader *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mu.Lock()
+	check := time.Since(reloader.checkedAt) >= reloader.interval
+	reloader.mu.Unlock()
+
+	if check {
+		if err := reloader.load(); err != nil {
+			logging.LogWith(err).Error("certificate reload error")
+		}
+	}
+
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+
+	return reloader.cert, nil
+}
+
+//Reload loads the key pair now if the files changed, e.g. on SIGHUP, without waiting for the next check.
+func (reloader *CertReloader) Reload() error {
+	return reloader.load()
+}
+
+func (reloader *CertReloader) load() error {
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+
+	reloader.checkedAt = time.Now()
+
+	modTime, err := lastModified(reloader.certFile, reloader.keyFile)
+
+	if err != nil {
+		return err
+	}
+
+	if reloader.cert != nil && !modTime.After(reloader.modTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+
+	if err != nil {
+		return err
+	}
+
+	if reloader.cert != nil {
+		logging.LogInfo("certificate %s reloaded", reloader.certFile)
+	}
+
+	reloader.cert = &cert
+	reloader.modTime = modTime
+
+	return nil
+}
+
+//NewTLSConfig creates a TLS config from settings, nil if TLS isn't enabled.
+func NewTLSConfig(settings TLSSettings) (*tls.Config, error) {
+	if !settings.Enabled() {
+		return nil, nil
+	}
+
+	reloader, err := NewCertReloader(settings.CertFile, settings.KeyFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if settings.ClientCAFile != "" {
+		data, err := ioutil.ReadFile(settings.ClientCAFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("invalid client CA bundle")
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+
+		if settings.RequireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return config, nil
+}
+
+//NewClientCertAuthService authenticates requests by their verified client certificate.
+func NewClientCertAuthService() AuthService {
+	return &clientCertAuth{}
+}
+
+func (service clientCertAuth) Authorize(req *http.Request) (Claims, error) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrClientCertMissing
+	}
+
+	cert := req.TLS.VerifiedChains[0][0]
+
+	return Claims{
+		"sub":         cert.Subject.CommonName,
+		"subject_dn":  cert.Subject.String(),
+		"issuer_dn":   cert.Issuer.String(),
+		"serial":      cert.SerialNumber.String(),
+		"exp":         float64(cert.NotAfter.Unix()),
+		"auth_method": AuthModeMTLS,
+	}, nil
+}
+
+func lastModified(files ...string) (time.Time, error) {
+	var latest time.Time
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+
+		if err != nil {
+			return latest, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,232 @@
+package api_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarulabs/di"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+
+	"github.com/getmilly/grok/api"
+)
+
+type certificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+type whoamiController struct{}
+
+func (ctrl *whoamiController) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/whoami", func(c *gin.Context) {
+		principal, _ := api.PrincipalFrom(c)
+		c.JSON(http.StatusOK, gin.H{
+			"sub":    principal.Subject(),
+			"method": principal.GetString("auth_method"),
+			"proto":  c.Request.Proto,
+		})
+	})
+}
+
+func issue(t *testing.T, name string, serial int64, parent *certificate) *certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	issuer, signer := template, key
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		issuer, signer = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return &certificate{cert: cert, key: key}
+}
+
+func (c *certificate) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+//write saves the pair as PEM files modified at modTime.
+func (c *certificate) write(t *testing.T, dir string, modTime time.Time) (string, string) {
+	key, err := x509.MarshalECPrivateKey(c.key)
+	assert.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600))
+	assert.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	assert.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+
+	return certFile, keyFile
+}
+
+func handshake(t *testing.T, addr string, roots *x509.CertPool) *x509.Certificate {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0]
+}
+
+func TestCertReloader_ServesRotatedCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := issue(t, "ca", 1, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	certFile, keyFile := issue(t, "server", 2, ca).write(t, dir, time.Now().Add(-time.Minute))
+
+	reloader, err := api.NewCertReloader(certFile, keyFile)
+	assert.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: reloader.GetCertificate})
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+
+			if err != nil {
+				return
+			}
+
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	assert.Equal(t, int64(2), handshake(t, listener.Addr().String(), roots).SerialNumber.Int64())
+
+	issue(t, "server", 3, ca).write(t, dir, time.Now())
+	assert.NoError(t, reloader.Reload())
+
+	assert.Equal(t, int64(3), handshake(t, listener.Addr().String(), roots).SerialNumber.Int64())
+}
+
+func TestServer_MutualTLSClaims(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mtls")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := issue(t, "ca", 1, nil)
+	certFile, keyFile := issue(t, "server", 2, ca).write(t, dir, time.Now())
+	caFile := filepath.Join(dir, "ca.crt")
+	assert.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0600))
+
+	settings := &api.Settings{Host: "127.0.0.1:0", Authorize: true}
+	settings.TLS = api.TLSSettings{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}
+	settings.Authorization.Modes = []string{api.AuthModeMTLS}
+
+	server := api.ConfigureServer(func() *api.Settings {
+		return settings
+	}, api.DefaultHealthChecks())
+
+	assert.NoError(t, server.AddController(di.Def{
+		Name:  "whoami-controller",
+		Scope: di.App,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return &whoamiController{}, nil
+		},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, server.Start(ctx))
+	defer server.Stop(ctx)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+
+	resp, err := client(issue(t, "billing", 4, ca).tls()).Get("https://" + server.Addr() + "/whoami")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"sub": "billing", "method": "mtls", "proto": "HTTP/1.1"}`, string(body))
+
+	anonymous, err := client().Get("https://" + server.Addr() + "/whoami")
+	assert.NoError(t, err)
+	anonymous.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, anonymous.StatusCode)
+}
+
+func TestServer_ServesH2C(t *testing.T) {
+	server := api.ConfigureServer(func() *api.Settings {
+		return &api.Settings{Host: "127.0.0.1:0", H2C: true}
+	}, api.DefaultHealthChecks())
+
+	assert.NoError(t, server.AddController(di.Def{
+		Name:  "ping-controller",
+		Scope: di.App,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return &pingController{}, nil
+		},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, server.Start(ctx))
+	defer server.Stop(ctx)
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+
+	resp, err := client.Get("http://" + server.Addr() + "/ping")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor)
+}
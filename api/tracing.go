@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/getmilly/grok/lifecycle"
+	"github.com/getmilly/grok/logging"
+	"github.com/getmilly/grok/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/sarulabs/di"
+)
+
+//SpanDef is the name of the request scoped server span in DI container.
+const SpanDef = "span"
+
+//Tracing starts a server span per request, continuing the W3C traceparent header.
+//The span is available from c.Request.Context() and, under SpanDef, the DI container.
+//Trace and span IDs and the route are attached to the request context logger.
+func Tracing(engine *gin.Engine, tracer *tracing.Tracer) gin.HandlerFunc {
+	routes := &routeTable{engine: engine}
+
+	return func(c *gin.Context) {
+		route := routes.template(c)
+
+		ctx := tracing.Extract(c.Request.Context(), tracing.HeaderCarrier(c.Request.Header))
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+route, tracing.SpanKindServer)
+		defer span.End()
+
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.target", c.Request.URL.Path)
+
+		sc := span.SpanContext()
+
+		c.Request = c.Request.WithContext(logging.ContextWithFields(ctx, map[string]interface{}{
+			logging.TraceIDField: sc.TraceID.String(),
+			logging.SpanIDField:  sc.SpanID.String(),
+			logging.RouteField:   route,
+		}))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttribute("http.status_code", status)
+
+		if status >= http.StatusInternalServerError {
+			message := http.StatusText(status)
+
+			if err := c.Errors.Last(); err != nil {
+				message = err.Error()
+			}
+
+			span.SetStatus(tracing.StatusError, message)
+		}
+	}
+}
+
+func (server *Server) configureTracing() {
+	switch {
+	case server.Settings.Tracer != nil:
+		server.Tracer = server.Settings.Tracer
+	case server.Settings.OTLPEndpoint != "":
+		server.Tracer = tracing.NewTracer(tracing.Options{
+			ServiceName: server.Settings.ApplicationName,
+			Exporter:    tracing.NewOTLPExporter(tracing.OTLPOptions{Endpoint: server.Settings.OTLPEndpoint}),
+			SampleRatio: server.Settings.TraceSampleRatio,
+		})
+	default:
+		server.Tracer = tracing.Default()
+		return
+	}
+
+	tracing.SetDefault(server.Tracer)
+	server.Lifecycle.Register(lifecycle.StageResources, "tracer", server.Tracer.Shutdown)
+}
+
+func spanDef() di.Def {
+	return di.Def{
+		Name:  SpanDef,
+		Scope: di.Request,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return &tracing.Span{}, nil
+		},
+	}
+}
+
+func setSpan(container di.Container, span *tracing.Span) {
+	if span == nil {
+		return
+	}
+
+	value, err := container.SafeGet(SpanDef)
+
+	if err != nil {
+		return
+	}
+
+	if target, ok := value.(*tracing.Span); ok {
+		*target = *span
+	}
+}
@@ -0,0 +1,65 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarulabs/di"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+	"github.com/getmilly/grok/tracing"
+)
+
+type tracedController struct{}
+
+func (ctrl *tracedController) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/orders/:id", func(c *gin.Context) {
+		container, _ := api.Container(c)
+		span := container.Get(api.SpanDef).(*tracing.Span)
+
+		c.String(http.StatusOK, span.SpanContext().TraceID.String())
+	})
+}
+
+func TestServer_TracesRequests(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+
+	server := api.ConfigureServer(func() *api.Settings {
+		return &api.Settings{
+			Host:   "127.0.0.1:0",
+			Tracer: tracing.NewTracer(tracing.Options{ServiceName: "orders", Exporter: exporter}),
+		}
+	}, api.DefaultHealthChecks())
+	defer tracing.SetDefault(tracing.NewTracer(tracing.Options{}))
+
+	err := server.AddController(di.Def{
+		Name:  "traced-controller",
+		Scope: di.App,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return &tracedController{}, nil
+		},
+	})
+	assert.NoError(t, err)
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/orders/42", nil)
+	req.Header.Set(tracing.TraceparentKey, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	spans := exporter.Spans()
+
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "GET /orders/:id", spans[0].Name)
+	assert.Equal(t, tracing.SpanKindServer, spans[0].Kind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.String())
+	assert.Equal(t, http.StatusOK, spans[0].Attributes["http.status_code"])
+}
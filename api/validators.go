@@ -0,0 +1,303 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	auth0 "github.com/auth0-community/go-auth0"
+	"github.com/prometheus/client_golang/prometheus"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+//Supported authorization modes.
+const (
+	AuthModeJWKS          = "jwks"
+	AuthModeHS256         = "hs256"
+	AuthModePEM           = "pem"
+	AuthModeIntrospection = "introspection"
+)
+
+var (
+	//ErrNoAuthServices is returned by a chain without services.
+	ErrNoAuthServices = errors.New("No auth services configured")
+	//ErrUnsupportedKey is returned when a PEM block has an unsupported key type.
+	ErrUnsupportedKey = errors.New("Unsupported public key type")
+	//ErrWeakSecret is returned when an HS256 secret is shorter than MinSecretSize.
+	ErrWeakSecret = fmt.Errorf("HS256 secret must have at least %d bytes", MinSecretSize)
+)
+
+//MinSecretSize is the minimum length in bytes of HS256 secrets, the size of the hash output.
+const MinSecretSize = 32
+
+type chain struct {
+	services []AuthService
+}
+
+//algorithms validates tokens with the service for the algorithm in their header.
+type algorithms map[jose.SignatureAlgorithm]AuthService
+
+//rsaAlgorithms are accepted for RSA public keys.
+var rsaAlgorithms = []jose.SignatureAlgorithm{jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512}
+
+//NewAuthServiceFromSettings creates the auth services configured in settings.
+//When several modes are set, they are tried in order.
+func NewAuthServiceFromSettings(settings AuthorizationSettings) (AuthService, error) {
+	service, collectors, err := newAuthService(settings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	registerer := settings.registerer()
+
+	for _, collector := range collectors {
+		if err := replaceCollector(registerer, collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return service, nil
+}
+
+//newAuthService creates the services configured in settings and their collectors, unregistered.
+func newAuthService(settings AuthorizationSettings) (AuthService, []prometheus.Collector, error) {
+	modes := settings.Modes
+
+	if len(modes) == 0 && len(settings.Services) == 0 {
+		modes = []string{AuthModeJWKS}
+	}
+
+	var services []AuthService
+	var collectors []prometheus.Collector
+
+	for _, mode := range modes {
+		service, collector, err := newAuthServiceForMode(strings.TrimSpace(strings.ToLower(mode)), settings)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		services = append(services, service)
+
+		if collector != nil {
+			collectors = append(collectors, collector)
+		}
+	}
+
+	services = append(services, settings.Services...)
+
+	if len(services) == 1 {
+		return services[0], collectors, nil
+	}
+
+	return NewChainAuthService(services...), collectors, nil
+}
+
+//NewHS256AuthService validates tokens signed with a shared secret of at least MinSecretSize bytes.
+func NewHS256AuthService(secret []byte, issuer string, audience []string) (AuthService, error) {
+	if len(secret) < MinSecretSize {
+		return nil, ErrWeakSecret
+	}
+
+	return NewKeyAuthService(secret, jose.HS256, issuer, audience), nil
+}
+
+//NewPEMAuthService validates tokens against a static PEM encoded public key or certificate.
+//RSA keys accept the RS and PS algorithms, ECDSA keys the ES algorithm of their curve.
+func NewPEMAuthService(data []byte, issuer string, audience []string) (AuthService, error) {
+	key, err := parsePublicKey(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		services := make(algorithms)
+
+		for _, algorithm := range rsaAlgorithms {
+			services[algorithm] = NewKeyAuthService(key, algorithm, issuer, audience)
+		}
+
+		return services, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return NewKeyAuthService(key, jose.ES256, issuer, audience), nil
+		case elliptic.P384():
+			return NewKeyAuthService(key, jose.ES384, issuer, audience), nil
+		case elliptic.P521():
+			return NewKeyAuthService(key, jose.ES512, issuer, audience), nil
+		}
+	}
+
+	return nil, ErrUnsupportedKey
+}
+
+//NewKeyAuthService validates tokens against a static key and algorithm.
+func NewKeyAuthService(key interface{}, algorithm jose.SignatureAlgorithm, issuer string, audience []string) AuthService {
+	configuration := auth0.NewConfiguration(auth0.NewKeyProvider(key), audience, issuer, algorithm)
+
+	return &auth{
+		Issuer:    issuer,
+		Audience:  audience,
+		validator: auth0.NewValidator(configuration, nil),
+	}
+}
+
+//NewChainAuthService tries each service in order until one authorizes the request.
+//When none does, it returns the most specific error, the first one on ties, so a
+//service that found no credential doesn't hide why another rejected the token.
+func NewChainAuthService(services ...AuthService) AuthService {
+	return &chain{services: services}
+}
+
+func (services algorithms) Authorize(req *http.Request) (Claims, error) {
+	token, err := auth0.FromHeader(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(token.Headers) < 1 {
+		return nil, auth0.ErrNoJWTHeaders
+	}
+
+	service, ok := services[jose.SignatureAlgorithm(token.Headers[0].Algorithm)]
+
+	if !ok {
+		return nil, auth0.ErrInvalidAlgorithm
+	}
+
+	return service.Authorize(req)
+}
+
+func (service chain) Authorize(req *http.Request) (Claims, error) {
+	var first error
+
+	for _, s := range service.services {
+		claims, err := s.Authorize(req)
+
+		if err == nil {
+			return claims, nil
+		}
+
+		if first == nil || specificity(err) > specificity(first) {
+			first = err
+		}
+	}
+
+	if first == nil {
+		return nil, ErrNoAuthServices
+	}
+
+	return nil, first
+}
+
+//specificity ranks errors: missing credentials, then malformed tokens, then any other rejection.
+func specificity(err error) int {
+	switch ClassifyAuthError(err).Code {
+	case CodeTokenMissing:
+		return 0
+	case CodeTokenMalformed, CodeInvalidToken:
+		return 1
+	}
+
+	return 2
+}
+
+//newAuthServiceForMode creates the service for mode and its collector, if any.
+func newAuthServiceForMode(mode string, settings AuthorizationSettings) (AuthService, prometheus.Collector, error) {
+	switch mode {
+	case AuthModeJWKS:
+		keys := NewJWKSCache(settings.JwksURI, DefaultKeyCacheOptions())
+		return NewCachedAuthService(keys, settings.Issuer, settings.Audience), keys, nil
+	case AuthModeHS256:
+		service, err := NewHS256AuthService([]byte(settings.Secret), settings.Issuer, settings.Audience)
+		return service, nil, err
+	case AuthModePEM:
+		data, err := ioutil.ReadFile(settings.PublicKeyFile)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		service, err := NewPEMAuthService(data, settings.Issuer, settings.Audience)
+		return service, nil, err
+	case AuthModeIntrospection:
+		return NewIntrospectionAuthService(settings.IntrospectionURI, IntrospectionOptions{
+			ClientID:     settings.IntrospectionClientID,
+			ClientSecret: settings.IntrospectionClientSecret,
+			Issuer:       settings.Issuer,
+			Audience:     settings.Audience,
+			MaxCacheTTL:  settings.IntrospectionCacheTTL,
+		}), nil, nil
+	case AuthModeAPIKey:
+		store, err := NewEnvFileAPIKeyStore(settings.APIKeysFile)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return NewAPIKeyAuthService(store), nil, nil
+	case AuthModeMTLS:
+		return NewClientCertAuthService(), nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown auth mode `%s`", mode)
+}
+
+//replaceCollector registers collector in place of one with the same metrics,
+//e.g. the JWKS cache created before a reload, so metrics report the cache in use.
+func replaceCollector(registerer prometheus.Registerer, collector prometheus.Collector) error {
+	err := registerer.Register(collector)
+
+	if registered, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		registerer.Unregister(registered.ExistingCollector)
+		err = registerer.Register(collector)
+	}
+
+	return err
+}
+
+func parsePublicKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return cert.PublicKey, nil
+	}
+
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func bearerToken(req *http.Request) (string, error) {
+	header := req.Header.Get("Authorization")
+
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:]), nil
+	}
+
+	return "", auth0.ErrTokenNotFound
+}
@@ -0,0 +1,296 @@
+package api_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/getmilly/grok/api"
+)
+
+func hs256Token(t *testing.T, secret []byte) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: secret}, nil)
+	assert.NoError(t, err)
+
+	raw, err := jwt.Signed(signer).Claims(jwt.Claims{
+		Subject:  "service",
+		Issuer:   "issuer",
+		Audience: jwt.Audience{"audience"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).CompactSerialize()
+	assert.NoError(t, err)
+
+	return raw
+}
+
+func hs256Service(t *testing.T, secret []byte) api.AuthService {
+	service, err := api.NewHS256AuthService(secret, "issuer", []string{"audience"})
+	assert.NoError(t, err)
+
+	return service
+}
+
+var introspectionOptions = api.IntrospectionOptions{ClientID: "client", ClientSecret: "secret"}
+
+func introspectionServer(t *testing.T, active string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "client", user)
+		assert.Equal(t, "secret", pass)
+
+		r.ParseForm()
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"active": r.PostForm.Get("token") == active,
+			"sub":    "partner",
+			"scope":  "orders:read",
+		})
+	}))
+}
+
+func TestHS256AuthService_Authorize(t *testing.T) {
+	secret := []byte("a-very-long-shared-secret-value!")
+	service := hs256Service(t, secret)
+
+	claims, err := service.Authorize(authorizedRequest(hs256Token(t, secret)))
+	assert.NoError(t, err)
+	assert.Equal(t, "service", claims.Subject())
+
+	_, err = service.Authorize(authorizedRequest(hs256Token(t, []byte("another-secret-with-enough-bytes"))))
+	assert.Error(t, err)
+}
+
+func TestHS256AuthService_RejectsWeakSecrets(t *testing.T) {
+	for _, secret := range []string{"", "short-secret"} {
+		service, err := api.NewHS256AuthService([]byte(secret), "issuer", []string{"audience"})
+
+		assert.Nil(t, service)
+		assert.Equal(t, api.ErrWeakSecret, err)
+	}
+}
+
+func TestPEMAuthService_ChoosesAlgorithmFromCurve(t *testing.T) {
+	curves := map[elliptic.Curve]jose.SignatureAlgorithm{
+		elliptic.P256(): jose.ES256,
+		elliptic.P384(): jose.ES384,
+		elliptic.P521(): jose.ES512,
+	}
+
+	for curve, algorithm := range curves {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		assert.NoError(t, err)
+
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		assert.NoError(t, err)
+
+		service, err := api.NewPEMAuthService(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), "issuer", []string{"audience"})
+		assert.NoError(t, err)
+
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: algorithm, Key: key}, nil)
+		assert.NoError(t, err)
+
+		raw, err := jwt.Signed(signer).Claims(jwt.Claims{
+			Subject:  "service",
+			Issuer:   "issuer",
+			Audience: jwt.Audience{"audience"},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}).CompactSerialize()
+		assert.NoError(t, err)
+
+		claims, err := service.Authorize(authorizedRequest(raw))
+		assert.NoError(t, err, string(algorithm))
+		assert.Equal(t, "service", claims.Subject())
+	}
+}
+
+func TestPEMAuthService_AcceptsRSAAlgorithms(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+
+	service, err := api.NewPEMAuthService(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), "issuer", []string{"audience"})
+	assert.NoError(t, err)
+
+	sign := func(algorithm jose.SignatureAlgorithm, key interface{}) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: algorithm, Key: key}, nil)
+		assert.NoError(t, err)
+
+		raw, err := jwt.Signed(signer).Claims(jwt.Claims{
+			Subject:  "service",
+			Issuer:   "issuer",
+			Audience: jwt.Audience{"audience"},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}).CompactSerialize()
+		assert.NoError(t, err)
+
+		return raw
+	}
+
+	for _, algorithm := range []jose.SignatureAlgorithm{jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS512} {
+		claims, err := service.Authorize(authorizedRequest(sign(algorithm, key)))
+		assert.NoError(t, err, string(algorithm))
+		assert.Equal(t, "service", claims.Subject())
+	}
+
+	_, err = service.Authorize(authorizedRequest(sign(jose.HS256, []byte("a-very-long-shared-secret-value!"))))
+	assert.Error(t, err)
+}
+
+func TestIntrospectionAuthService_Authorize(t *testing.T) {
+	server := introspectionServer(t, "opaque")
+	defer server.Close()
+
+	service := api.NewIntrospectionAuthService(server.URL, introspectionOptions)
+
+	claims, err := service.Authorize(authorizedRequest("opaque"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"orders:read"}, claims.Scopes())
+
+	_, err = service.Authorize(authorizedRequest("revoked"))
+	assert.Equal(t, api.ErrInactiveToken, err)
+}
+
+func TestIntrospectionAuthService_CachesActiveTokensUntilExpiry(t *testing.T) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		r.ParseForm()
+
+		exp := time.Now().Add(time.Hour)
+
+		if r.PostForm.Get("token") == "expiring" {
+			exp = time.Now().Add(-time.Second)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"active": true,
+			"sub":    "partner",
+			"iss":    "issuer",
+			"aud":    "audience",
+			"exp":    exp.Unix(),
+		})
+	}))
+	defer server.Close()
+
+	service := api.NewIntrospectionAuthService(server.URL, api.IntrospectionOptions{
+		Issuer:   "issuer",
+		Audience: []string{"audience"},
+	})
+
+	for i := 0; i < 3; i++ {
+		claims, err := service.Authorize(authorizedRequest("opaque"))
+		assert.NoError(t, err)
+		assert.Equal(t, "partner", claims.Subject())
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	for i := 0; i < 2; i++ {
+		_, err := service.Authorize(authorizedRequest("expiring"))
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestIntrospectionAuthService_RejectsOtherIssuersAndAudiences(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"active": true,
+			"iss":    "issuer",
+			"aud":    []string{"billing", "audience"},
+			"exp":    time.Now().Add(time.Hour).Unix(),
+		})
+	}))
+	defer server.Close()
+
+	_, err := api.NewIntrospectionAuthService(server.URL, api.IntrospectionOptions{
+		Issuer:   "issuer",
+		Audience: []string{"audience"},
+	}).Authorize(authorizedRequest("opaque"))
+	assert.NoError(t, err)
+
+	_, err = api.NewIntrospectionAuthService(server.URL, api.IntrospectionOptions{
+		Issuer: "another-issuer",
+	}).Authorize(authorizedRequest("opaque"))
+	assert.Equal(t, api.CodeInvalidIssuer, api.ClassifyAuthError(err).Code)
+
+	_, err = api.NewIntrospectionAuthService(server.URL, api.IntrospectionOptions{
+		Audience: []string{"orders"},
+	}).Authorize(authorizedRequest("opaque"))
+	assert.Equal(t, api.CodeInvalidAudience, api.ClassifyAuthError(err).Code)
+}
+
+func TestChainAuthService_Authorize(t *testing.T) {
+	server := introspectionServer(t, "opaque")
+	defer server.Close()
+
+	secret := []byte("a-very-long-shared-secret-value!")
+	service := api.NewChainAuthService(
+		hs256Service(t, secret),
+		api.NewIntrospectionAuthService(server.URL, introspectionOptions),
+	)
+
+	claims, err := service.Authorize(authorizedRequest(hs256Token(t, secret)))
+	assert.NoError(t, err)
+	assert.Equal(t, "service", claims.Subject())
+
+	claims, err = service.Authorize(authorizedRequest("opaque"))
+	assert.NoError(t, err)
+	assert.Equal(t, "partner", claims.Subject())
+
+	_, err = service.Authorize(authorizedRequest("revoked"))
+	assert.Error(t, err)
+}
+
+func TestChainAuthService_ReportsTheMostSpecificError(t *testing.T) {
+	server := introspectionServer(t, "opaque")
+	defer server.Close()
+
+	secret := []byte("a-very-long-shared-secret-value!")
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: secret}, nil)
+	assert.NoError(t, err)
+
+	expired, err := jwt.Signed(signer).Claims(jwt.Claims{
+		Subject:  "service",
+		Issuer:   "issuer",
+		Audience: jwt.Audience{"audience"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+	}).CompactSerialize()
+	assert.NoError(t, err)
+
+	service := api.NewChainAuthService(
+		api.NewAPIKeyAuthService(api.NewMemoryAPIKeyStore()),
+		hs256Service(t, secret),
+		api.NewIntrospectionAuthService(server.URL, introspectionOptions),
+	)
+
+	_, err = service.Authorize(authorizedRequest(expired))
+	assert.Equal(t, api.CodeTokenExpired, api.ClassifyAuthError(err).Code)
+
+	_, err = service.Authorize(authorizedRequest("revoked"))
+	assert.Equal(t, api.ErrInactiveToken, err)
+
+	_, err = service.Authorize(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, api.CodeTokenMissing, api.ClassifyAuthError(err).Code)
+
+	_, err = api.NewChainAuthService().Authorize(authorizedRequest(expired))
+	assert.Equal(t, api.ErrNoAuthServices, err)
+}
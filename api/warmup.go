@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/getmilly/grok/logging"
+	"github.com/gin-gonic/gin"
+)
+
+//Startup and warm-up step statuses reported by StartupReport.
+const (
+	StatusStarting = "starting"
+	StatusStarted  = "started"
+	StatusFailed   = "failed"
+
+	StepPending = "pending"
+	StepRunning = "running"
+	StepDone    = "done"
+	StepFailed  = "failed"
+)
+
+//WarmupHook prepares the service before it's ready, e.g. cache priming,
+//migrations or JWKS prefetch.
+type WarmupHook func(ctx context.Context) error
+
+//WarmupStep is the progress of one warm-up hook.
+type WarmupStep struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+//StartupReport is answered by /healthz/startup.
+type StartupReport struct {
+	Status    string       `json:"status"`
+	Completed int          `json:"completed"`
+	Total     int          `json:"total"`
+	Steps     []WarmupStep `json:"steps"`
+}
+
+type warmup struct {
+	mu     sync.RWMutex
+	hooks  []WarmupHook
+	steps  []WarmupStep
+	status string
+	err    error
+}
+
+//AddWarmup registers a hook run in order after Start, before readiness goes green.
+//Hooks share Settings.WarmupTimeout, the server shuts down if they fail or take longer.
+func (server *Server) AddWarmup(name string, hook WarmupHook) {
+	server.warmup.mu.Lock()
+	defer server.warmup.mu.Unlock()
+
+	server.warmup.hooks = append(server.warmup.hooks, hook)
+	server.warmup.steps = append(server.warmup.steps, WarmupStep{Name: name, Status: StepPending})
+}
+
+//Started reports whether every warm-up hook completed.
+func (server *Server) Started() bool {
+	return server.StartupReport().Status == StatusStarted
+}
+
+//StartupReport returns the warm-up progress.
+func (server *Server) StartupReport() StartupReport {
+	server.warmup.mu.RLock()
+	defer server.warmup.mu.RUnlock()
+
+	report := StartupReport{
+		Status: server.warmup.status,
+		Total:  len(server.warmup.steps),
+		Steps:  append([]WarmupStep{}, server.warmup.steps...),
+	}
+
+	for _, step := range report.Steps {
+		if step.Status == StepDone {
+			report.Completed++
+		}
+	}
+
+	if report.Status == "" {
+		report.Status = StatusStarting
+
+		if report.Completed == report.Total {
+			report.Status = StatusStarted
+		}
+	}
+
+	return report
+}
+
+func (server *Server) runWarmup() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if server.Settings.WarmupTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, server.Settings.WarmupTimeout)
+		defer cancel()
+	}
+
+	go func() {
+		select {
+		case <-server.Lifecycle.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	server.warmup.mu.RLock()
+	hooks := server.warmup.hooks
+	server.warmup.mu.RUnlock()
+
+	for i, hook := range hooks {
+		if err := server.runWarmupStep(ctx, i, hook); err != nil {
+			if server.Lifecycle.ShuttingDown() {
+				logging.LogWith(err).Info("warm-up interrupted by shutdown")
+				return
+			}
+
+			server.failWarmup(err)
+			return
+		}
+	}
+
+	server.warmup.mu.Lock()
+	server.warmup.status = StatusStarted
+	server.warmup.mu.Unlock()
+
+	if len(hooks) > 0 {
+		logging.LogInfo("warm-up completed")
+	}
+}
+
+func (server *Server) runWarmupStep(ctx context.Context, i int, hook WarmupHook) error {
+	started := time.Now()
+	server.setStep(i, StepRunning, 0, nil)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- hook(ctx)
+	}()
+
+	var err error
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		server.setStep(i, StepFailed, time.Since(started), err)
+
+		server.warmup.mu.RLock()
+		name := server.warmup.steps[i].Name
+		server.warmup.mu.RUnlock()
+
+		return fmt.Errorf("warm-up %s: %v", name, err)
+	}
+
+	server.setStep(i, StepDone, time.Since(started), nil)
+	return nil
+}
+
+func (server *Server) setStep(i int, status string, duration time.Duration, err error) {
+	server.warmup.mu.Lock()
+	defer server.warmup.mu.Unlock()
+
+	step := &server.warmup.steps[i]
+	step.Status = status
+	step.DurationMs = float64(duration) / float64(time.Millisecond)
+
+	if err != nil {
+		step.Error = err.Error()
+	}
+}
+
+func (server *Server) failWarmup(err error) {
+	server.warmup.mu.Lock()
+	server.warmup.status = StatusFailed
+	server.warmup.err = err
+	server.warmup.mu.Unlock()
+
+	logging.LogWith(err).Error("warm-up error")
+	server.Lifecycle.Fail(err)
+}
+
+func (server *Server) warmupErr() error {
+	server.warmup.mu.RLock()
+	defer server.warmup.mu.RUnlock()
+
+	return server.warmup.err
+}
+
+func (server *Server) startup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := server.StartupReport()
+
+		if report.Status != StatusStarted {
+			c.JSON(http.StatusServiceUnavailable, report)
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
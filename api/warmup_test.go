@@ -0,0 +1,135 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+)
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(time.Second)
+
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServer_WarmupGatesReadiness(t *testing.T) {
+	server := testServer(t)
+	release := make(chan struct{})
+
+	server.AddWarmup("jwks", func(ctx context.Context) error {
+		return nil
+	})
+	server.AddWarmup("cache", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, server.Start(ctx))
+	defer server.Stop(ctx)
+
+	base := "http://" + server.Addr()
+
+	waitFor(t, func() bool {
+		return server.StartupReport().Completed == 1
+	})
+
+	status, body := get(t, base+"/healthz/startup")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	var report api.StartupReport
+	assert.NoError(t, json.Unmarshal([]byte(body), &report))
+	assert.Equal(t, api.StatusStarting, report.Status)
+	assert.Equal(t, 2, report.Total)
+	assert.Equal(t, api.StepRunning, report.Steps[1].Status)
+
+	status, _ = get(t, base+"/healthz/readiness")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	close(release)
+
+	waitFor(t, server.Started)
+
+	status, _ = get(t, base+"/healthz/startup")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = get(t, base+"/healthz/readiness")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestServer_WarmupTimeoutShutsDown(t *testing.T) {
+	server := api.ConfigureServer(func() *api.Settings {
+		return &api.Settings{Host: "127.0.0.1:0", WarmupTimeout: 20 * time.Millisecond}
+	}, api.DefaultHealthChecks())
+
+	server.AddWarmup("migrations", func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("interrupted")
+	})
+
+	assert.NoError(t, server.Start(context.Background()))
+
+	select {
+	case <-server.Lifecycle.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("server not shut down")
+	}
+
+	report := server.StartupReport()
+	assert.Equal(t, api.StatusFailed, report.Status)
+	assert.Equal(t, api.StepFailed, report.Steps[0].Status)
+}
+
+func TestServer_RunReturnsWarmupError(t *testing.T) {
+	server := testServer(t)
+
+	server.AddWarmup("migrations", func(ctx context.Context) error {
+		return errors.New("dirty schema")
+	})
+
+	assert.EqualError(t, server.Run(), "warm-up migrations: dirty schema")
+}
+
+func TestServer_ShutdownDuringWarmupIsGraceful(t *testing.T) {
+	server := testServer(t)
+	started := make(chan struct{})
+
+	server.AddWarmup("cache", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- server.Run()
+	}()
+
+	<-started
+	server.Lifecycle.Shutdown()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("server not shut down")
+	}
+
+	assert.NotEqual(t, api.StatusFailed, server.StartupReport().Status)
+}
@@ -0,0 +1,441 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	yaml "gopkg.in/yaml.v2"
+)
+
+//Validator is implemented by settings with rules tags can't express.
+type Validator interface {
+	Validate() error
+}
+
+//Error lists every missing or malformed setting.
+type Error struct {
+	Problems []string
+}
+
+func (err *Error) Error() string {
+	return "invalid settings: " + strings.Join(err.Problems, "; ")
+}
+
+//Result describes what Load read.
+type Result struct {
+	//Paths are the settings files read, watched by Store.
+	Paths []string
+
+	resolved map[interface{}]bool
+}
+
+//Option configures where values are loaded from.
+type Option func(*loader) error
+
+type loader struct {
+	files     []func(v interface{}) error
+	paths     []string
+	dotenv    map[string]string
+	flags     map[string]string
+	lookup    func(string) (string, bool)
+	providers map[string]SecretProvider
+	resolved  map[interface{}]bool
+	errors    []string
+	problem   map[string]bool
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+//DotEnv reads variables from dotenv files, real environment variables win.
+//Without files `.env` is read if it exists.
+func DotEnv(files ...string) Option {
+	return func(l *loader) error {
+		paths := files
+		optional := len(paths) == 0
+
+		if optional {
+			paths = []string{".env"}
+		}
+
+		for _, file := range paths {
+			values, err := godotenv.Read(file)
+
+			if err != nil {
+				if optional && os.IsNotExist(err) {
+					continue
+				}
+
+				return err
+			}
+
+			for k, v := range values {
+				l.dotenv[k] = v
+			}
+
+			l.paths = append(l.paths, file)
+		}
+
+		return nil
+	}
+}
+
+//File decodes a YAML or JSON file, by extension, into the settings.
+//Keys follow `yaml` and `json` tags and are overridden by environment variables.
+func File(path string) Option {
+	return func(l *loader) error {
+		data, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			return err
+		}
+
+		l.paths = append(l.paths, path)
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			l.files = append(l.files, func(v interface{}) error {
+				return yaml.Unmarshal(data, v)
+			})
+		case ".json":
+			l.files = append(l.files, func(v interface{}) error {
+				return json.Unmarshal(data, v)
+			})
+		default:
+			return fmt.Errorf("unsupported settings file `%s`", path)
+		}
+
+		return nil
+	}
+}
+
+//Lookup replaces os.LookupEnv as the environment source.
+func Lookup(lookup func(string) (string, bool)) Option {
+	return func(l *loader) error {
+		l.lookup = lookup
+		return nil
+	}
+}
+
+//Flags reads `--name=value` or `--name value` arguments, the highest precedence source.
+//Names are env names lower cased with `_` replaced by `-`, e.g. `--jwks-uri`.
+func Flags(args []string) Option {
+	return func(l *loader) error {
+		for i := 0; i < len(args); i++ {
+			arg := args[i]
+
+			if !strings.HasPrefix(arg, "-") {
+				continue
+			}
+
+			name := strings.TrimLeft(arg, "-")
+			value := ""
+
+			if eq := strings.Index(name, "="); eq >= 0 {
+				name, value = name[:eq], name[eq+1:]
+			} else if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+				value = args[i]
+			} else {
+				value = "true"
+			}
+
+			l.flags[name] = value
+		}
+
+		return nil
+	}
+}
+
+//Load fills the struct pointed by v from defaults, files, environment and flags, in this order.
+//
+//Fields are configured with tags:
+//	env:"NAME,OLD_NAME"  variable names, the first one set wins
+//	                     set to empty, e.g. `LOG_LEVEL=`, clears string fields and is ignored by others
+//	default:"value"      used when no source sets the field
+//	required:"true"      the field can't be left zero
+//	prefix:"AUTH_"       prepended to env names of a nested struct
+//	secret:"true"        the value is hidden by Redact and in errors
+//
+//String values like `file:///run/secrets/name` or `env://OTHER_VAR` are
+//resolved by the SecretProvider registered for their scheme, see Secrets.
+//
+//Slices are comma separated and durations use time.ParseDuration.
+func Load(v interface{}, options ...Option) (*Result, error) {
+	root := reflect.ValueOf(v)
+
+	if root.Kind() != reflect.Ptr || root.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("settings must be a pointer to struct, got %T", v)
+	}
+
+	l, err := newLoader(options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	l.walk(root.Elem(), "", l.applyDefault)
+
+	for _, decode := range l.files {
+		if err := decode(v); err != nil {
+			return nil, err
+		}
+	}
+
+	l.walk(root.Elem(), "", l.applyEnv)
+	l.walk(root.Elem(), "", l.resolveSecrets)
+	l.walk(root.Elem(), "", l.checkRequired)
+	l.validate(root)
+
+	if len(l.errors) > 0 {
+		return nil, &Error{Problems: l.errors}
+	}
+
+	return &Result{Paths: l.paths, resolved: l.resolved}, nil
+}
+
+func newLoader(options []Option) (*loader, error) {
+	l := &loader{
+		dotenv:    make(map[string]string),
+		flags:     make(map[string]string),
+		lookup:    os.LookupEnv,
+		providers: map[string]SecretProvider{"file": FileSecrets()},
+		resolved:  make(map[interface{}]bool),
+		problem:   make(map[string]bool),
+	}
+
+	for _, option := range options {
+		if err := option(l); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := l.providers["env"]; !ok {
+		l.providers["env"] = EnvSecrets(func(name string) (string, bool) {
+			return l.lookupEnv(name, false)
+		})
+	}
+
+	return l, nil
+}
+
+type visitor func(field reflect.Value, tag reflect.StructTag, names []string)
+
+func (l *loader) walk(v reflect.Value, prefix string, visit visitor) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		field := v.Field(i)
+
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		env, tagged := sf.Tag.Lookup("env")
+
+		if !tagged && field.Kind() == reflect.Struct && !isScalar(field) {
+			l.walk(field, prefix+sf.Tag.Get("prefix"), visit)
+			continue
+		}
+
+		if !tagged || !field.CanSet() {
+			continue
+		}
+
+		var names []string
+
+		for _, name := range strings.Split(env, ",") {
+			names = append(names, prefix+strings.TrimSpace(name))
+		}
+
+		visit(field, sf.Tag, names)
+	}
+}
+
+func (l *loader) applyDefault(field reflect.Value, tag reflect.StructTag, names []string) {
+	value, ok := tag.Lookup("default")
+
+	if !ok || !isZero(field) {
+		return
+	}
+
+	if err := setValue(field, value); err != nil {
+		l.fail(fmt.Sprintf("%s: invalid default `%s`: %v", names[0], value, err))
+	}
+}
+
+func (l *loader) applyEnv(field reflect.Value, tag reflect.StructTag, names []string) {
+	for _, name := range names {
+		flag := strings.Replace(strings.ToLower(name), "_", "-", -1)
+
+		if value, ok := l.flags[flag]; ok {
+			if err := setValue(field, value); err != nil {
+				l.fail(fmt.Sprintf("--%s: invalid value `%s`: %v", flag, display(tag, value), err))
+			}
+
+			return
+		}
+	}
+
+	for _, name := range names {
+		value, ok := l.lookupEnv(name, field.Kind() == reflect.String)
+
+		if !ok {
+			continue
+		}
+
+		if err := setValue(field, value); err != nil {
+			l.fail(fmt.Sprintf("%s: invalid value `%s`: %v", name, display(tag, value), err))
+		}
+
+		return
+	}
+}
+
+func (l *loader) checkRequired(field reflect.Value, tag reflect.StructTag, names []string) {
+	if required, _ := strconv.ParseBool(tag.Get("required")); required && isZero(field) {
+		l.fail(fmt.Sprintf("%s is required", names[0]))
+	}
+}
+
+func (l *loader) validate(v reflect.Value) {
+	if v.Kind() == reflect.Ptr {
+		if validator, ok := v.Interface().(Validator); ok {
+			if err := validator.Validate(); err != nil {
+				l.merge(err)
+			}
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Struct && field.CanAddr() && v.Type().Field(i).PkgPath == "" {
+			l.validate(field.Addr())
+		}
+	}
+}
+
+func (l *loader) merge(err error) {
+	if validation, ok := err.(*Error); ok {
+		for _, problem := range validation.Problems {
+			l.fail(problem)
+		}
+		return
+	}
+
+	l.fail(err.Error())
+}
+
+func (l *loader) fail(problem string) {
+	if l.problem[problem] {
+		return
+	}
+
+	l.problem[problem] = true
+	l.errors = append(l.errors, problem)
+}
+
+func setValue(field reflect.Value, raw string) error {
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+
+		if err != nil {
+			return err
+		}
+
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetFloat(f)
+	case reflect.Slice:
+		parts := strings.Split(raw, ",")
+		slice := reflect.MakeSlice(field.Type(), 0, len(parts))
+
+		for _, part := range parts {
+			item := reflect.New(field.Type().Elem()).Elem()
+
+			if err := setValue(item, strings.TrimSpace(part)); err != nil {
+				return err
+			}
+
+			slice = reflect.Append(slice, item)
+		}
+
+		field.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
+
+func isScalar(v reflect.Value) bool {
+	return v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType)
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
@@ -0,0 +1,142 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+	"github.com/getmilly/grok/config"
+)
+
+type mongoSettings struct {
+	URI      string        `env:"URI" required:"true"`
+	Timeout  time.Duration `env:"TIMEOUT" default:"10s"`
+	Replicas []string      `env:"REPLICAS"`
+}
+
+type appSettings struct {
+	api.Settings
+	Mongo   mongoSettings `prefix:"MONGO_"`
+	Workers int           `env:"WORKERS" default:"4" yaml:"workers"`
+}
+
+func env(values map[string]string) config.Option {
+	return config.Lookup(func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	})
+}
+
+func TestLoad_PopulatesNestedAndEmbeddedSettings(t *testing.T) {
+	settings := &appSettings{}
+
+	_, err := config.Load(settings, env(map[string]string{
+		"HOST":           ":8080",
+		"AUTHORIZE":      "false",
+		"ISSUSER":        "legacy-issuer",
+		"MONGO_URI":      "mongodb://localhost",
+		"MONGO_REPLICAS": "a, b",
+		"READ_TIMEOUT":   "2s",
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, ":8080", settings.Host)
+	assert.False(t, settings.Authorize)
+	assert.Equal(t, "legacy-issuer", settings.Authorization.Issuer)
+	assert.Equal(t, []string{"jwks"}, settings.Authorization.Modes)
+	assert.Equal(t, 2*time.Second, settings.ReadTimeout)
+	assert.Equal(t, "mongodb://localhost", settings.Mongo.URI)
+	assert.Equal(t, 10*time.Second, settings.Mongo.Timeout)
+	assert.Equal(t, []string{"a", "b"}, settings.Mongo.Replicas)
+	assert.Equal(t, 4, settings.Workers)
+}
+
+func TestLoad_EmptyVariablesClearStrings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dotenvFile := filepath.Join(dir, ".env")
+	ioutil.WriteFile(dotenvFile, []byte("LOG_LEVEL=info\nWORKERS=8\n"), 0600)
+
+	settings := &appSettings{}
+
+	_, err = config.Load(settings, config.DotEnv(dotenvFile), env(map[string]string{
+		"HOST":      ":8080",
+		"AUTHORIZE": "false",
+		"MONGO_URI": "mongodb://localhost",
+		"LOG_LEVEL": "",
+		"ISSUER":    "",
+		"ISSUSER":   "legacy-issuer",
+		"WORKERS":   "",
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "", settings.LogLevel)
+	assert.Equal(t, "", settings.Authorization.Issuer)
+	assert.Equal(t, 8, settings.Workers)
+}
+
+func TestLoad_AggregatesEveryProblem(t *testing.T) {
+	settings := &appSettings{}
+
+	_, err := config.Load(settings, env(map[string]string{
+		"AUTHORIZE":     "maybe",
+		"AUTH_MODE":     "hs256",
+		"MONGO_TIMEOUT": "soon",
+	}))
+
+	assert.Error(t, err)
+
+	problems := err.(*config.Error).Problems
+
+	assert.Contains(t, problems, "HOST is required")
+	assert.Contains(t, problems, "MONGO_URI is required")
+	assert.Contains(t, problems, "AUTH_SECRET is required by AUTH_MODE")
+	assert.Len(t, problems, 5)
+}
+
+func TestLoad_FilesAreOverriddenByEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	yamlFile := filepath.Join(dir, "settings.yaml")
+	dotenvFile := filepath.Join(dir, ".env")
+
+	ioutil.WriteFile(yamlFile, []byte("workers: 8\n"), 0600)
+	ioutil.WriteFile(dotenvFile, []byte("HOST=:9090\nMONGO_URI=mongodb://dotenv\n"), 0600)
+
+	settings := &appSettings{}
+
+	result, err := config.Load(settings,
+		config.File(yamlFile),
+		config.DotEnv(dotenvFile),
+		env(map[string]string{"AUTHORIZE": "false", "MONGO_URI": "mongodb://env"}),
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{yamlFile, dotenvFile}, result.Paths)
+	assert.Equal(t, 8, settings.Workers)
+	assert.Equal(t, ":9090", settings.Host)
+	assert.Equal(t, "mongodb://env", settings.Mongo.URI)
+}
+
+func TestLoad_RejectsWeakSecrets(t *testing.T) {
+	settings := &appSettings{}
+
+	_, err := config.Load(settings, env(map[string]string{
+		"HOST":        ":8080",
+		"MONGO_URI":   "mongodb://localhost",
+		"AUTH_MODE":   "hs256",
+		"AUTH_SECRET": "short",
+	}))
+
+	assert.Error(t, err)
+	assert.Equal(t, []string{"AUTH_SECRET: must have at least 32 bytes"}, err.(*config.Error).Problems)
+}
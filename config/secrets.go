@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+)
+
+//Redacted replaces secret values in Redact output and error messages.
+const Redacted = "[REDACTED]"
+
+//SecretProvider resolves the part after `scheme://` of a secret reference.
+type SecretProvider interface {
+	Resolve(ref string) (string, error)
+}
+
+//SecretProviderFunc adapts a function to SecretProvider.
+type SecretProviderFunc func(ref string) (string, error)
+
+//Resolve calls fn(ref).
+func (fn SecretProviderFunc) Resolve(ref string) (string, error) {
+	return fn(ref)
+}
+
+//FileSecrets reads `file:///run/secrets/name`, trimming the trailing newline.
+func FileSecrets() SecretProvider {
+	return SecretProviderFunc(func(ref string) (string, error) {
+		data, err := ioutil.ReadFile(ref)
+
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimRight(string(data), "\r\n"), nil
+	})
+}
+
+//EnvSecrets reads `env://OTHER_VAR`.
+func EnvSecrets(lookup func(string) (string, bool)) SecretProvider {
+	return SecretProviderFunc(func(ref string) (string, error) {
+		value, ok := lookup(ref)
+
+		if !ok {
+			return "", fmt.Errorf("variable %s not set", ref)
+		}
+
+		return value, nil
+	})
+}
+
+//Secrets registers a provider for references with the given scheme.
+//`file` and `env` are registered by default.
+func Secrets(scheme string, provider SecretProvider) Option {
+	return func(l *loader) error {
+		l.providers[scheme] = provider
+		return nil
+	}
+}
+
+//Redact returns a copy of settings as a map, safe to log.
+//Fields tagged `secret:"true"` are replaced by Redacted, see also Result.Redact.
+//Fields tagged `json:"-"` and interface, func and channel fields are left out.
+func Redact(v interface{}) interface{} {
+	return redactor{}.redact(reflect.ValueOf(v), false)
+}
+
+//Redact is like the package level Redact, also replacing the values this Load
+//resolved from references. v must be the pointer passed to Load.
+func (result *Result) Redact(v interface{}) interface{} {
+	return redactor{resolved: result.resolved}.redact(reflect.ValueOf(v), false)
+}
+
+//redactor masks secret fields and the fields in resolved, keyed by their address.
+type redactor struct {
+	resolved map[interface{}]bool
+}
+
+func (r redactor) redact(v reflect.Value, secret bool) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if isScalar(v) {
+			break
+		}
+
+		out := make(map[string]interface{})
+
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+
+			if sf.PkgPath != "" && !sf.Anonymous || sf.Tag.Get("json") == "-" || !loggable(sf.Type) {
+				continue
+			}
+
+			value := r.redact(v.Field(i), secret || sf.Tag.Get("secret") == "true")
+
+			if embedded, ok := value.(map[string]interface{}); ok && sf.Anonymous {
+				for k, v := range embedded {
+					out[k] = v
+				}
+				continue
+			}
+
+			out[sf.Name] = value
+		}
+
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+
+		out := make([]interface{}, v.Len())
+
+		for i := range out {
+			out[i] = r.redact(v.Index(i), secret)
+		}
+
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+
+		out := make(map[string]interface{}, v.Len())
+
+		for _, key := range v.MapKeys() {
+			out[fmt.Sprint(key.Interface())] = r.redact(v.MapIndex(key), secret)
+		}
+
+		return out
+	case reflect.String:
+		if secret && v.Len() > 0 || r.isResolved(v) {
+			return Redacted
+		}
+
+		return v.String()
+	}
+
+	if secret {
+		return Redacted
+	}
+
+	if !v.CanInterface() {
+		return nil
+	}
+
+	return v.Interface()
+}
+
+//loggable excludes live objects like loggers and registries, which hold internal state.
+func loggable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return false
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return loggable(t.Elem())
+	}
+
+	return true
+}
+
+func (r redactor) isResolved(v reflect.Value) bool {
+	return v.CanAddr() && v.CanInterface() && r.resolved[v.Addr().Interface()]
+}
+
+func (l *loader) resolveSecrets(field reflect.Value, tag reflect.StructTag, names []string) {
+	switch {
+	case field.Kind() == reflect.String:
+		l.resolve(field, names[0])
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		for i := 0; i < field.Len(); i++ {
+			l.resolve(field.Index(i), names[0])
+		}
+	}
+}
+
+func (l *loader) resolve(field reflect.Value, name string) {
+	value := field.String()
+	sep := strings.Index(value, "://")
+
+	if sep < 0 {
+		return
+	}
+
+	provider, ok := l.providers[value[:sep]]
+
+	if !ok {
+		return
+	}
+
+	secret, err := provider.Resolve(value[sep+3:])
+
+	if err != nil {
+		l.fail(fmt.Sprintf("%s: can't resolve `%s`: %v", name, value, err))
+		return
+	}
+
+	field.SetString(secret)
+	l.resolved[field.Addr().Interface()] = true
+}
+
+//lookupEnv returns a variable from the environment, then from dotenv files.
+//Empty values count as set only when empty is true.
+func (l *loader) lookupEnv(name string, empty bool) (string, bool) {
+	if value, ok := l.lookup(name); ok && (empty || value != "") {
+		return value, true
+	}
+
+	value, ok := l.dotenv[name]
+	return value, ok && (empty || value != "")
+}
+
+func display(tag reflect.StructTag, value string) string {
+	if tag.Get("secret") == "true" {
+		return Redacted
+	}
+
+	return value
+}
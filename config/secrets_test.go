@@ -0,0 +1,140 @@
+package config_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/config"
+	"github.com/getmilly/grok/logging"
+)
+
+func TestLoad_ResolvesSecretReferences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "mongo_uri")
+	ioutil.WriteFile(file, []byte("mongodb://user:pass@db\n"), 0600)
+
+	vault := config.SecretProviderFunc(func(ref string) (string, error) {
+		return "vault-" + ref, nil
+	})
+
+	settings := &appSettings{}
+
+	result, err := config.Load(settings,
+		config.Secrets("vault", vault),
+		env(map[string]string{
+			"HOST":        ":8080",
+			"AUTH_MODE":   "hs256",
+			"AUTH_SECRET": "env://SIGNING_KEY",
+			"SIGNING_KEY": "s3cr3t-signing-key-of-32-bytes!!",
+			"MONGO_URI":   "file://" + file,
+			"JWKS_URI":    "https://issuer/.well-known/jwks.json",
+			"AUDIENCE":    "vault://audience",
+		}),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t-signing-key-of-32-bytes!!", settings.Authorization.Secret)
+	assert.Equal(t, "mongodb://user:pass@db", settings.Mongo.URI)
+	assert.Equal(t, "https://issuer/.well-known/jwks.json", settings.Authorization.JwksURI)
+	assert.Equal(t, []string{"vault-audience"}, settings.Authorization.Audience)
+
+	redacted := result.Redact(settings).(map[string]interface{})
+	auth := redacted["Authorization"].(map[string]interface{})
+	mongo := redacted["Mongo"].(map[string]interface{})
+
+	assert.Equal(t, ":8080", redacted["Host"])
+	assert.Equal(t, config.Redacted, auth["Secret"])
+	assert.Equal(t, config.Redacted, mongo["URI"])
+	assert.Equal(t, "https://issuer/.well-known/jwks.json", auth["JwksURI"])
+}
+
+func TestLoad_ReportsUnresolvedSecrets(t *testing.T) {
+	failing := config.SecretProviderFunc(func(ref string) (string, error) {
+		return "", errors.New("sealed")
+	})
+
+	_, err := config.Load(&appSettings{},
+		config.Secrets("vault", failing),
+		env(map[string]string{
+			"HOST":      ":8080",
+			"AUTHORIZE": "false",
+			"MONGO_URI": "vault://mongo",
+		}),
+	)
+
+	assert.EqualError(t, err, "invalid settings: MONGO_URI: can't resolve `vault://mongo`: sealed")
+}
+
+func TestRedact_TracksSecretsPerLoad(t *testing.T) {
+	secrets := env(map[string]string{
+		"HOST":         ":8080",
+		"AUTHORIZE":    "false",
+		"MONGO_URI":    "env://MONGO_SECRET",
+		"MONGO_SECRET": "mongodb://shared",
+	})
+
+	first := &appSettings{}
+	_, err := config.Load(first, secrets)
+	assert.NoError(t, err)
+
+	plain := &appSettings{}
+	result, err := config.Load(plain, env(map[string]string{
+		"HOST":      ":8080",
+		"AUTHORIZE": "false",
+		"MONGO_URI": "mongodb://shared",
+	}))
+	assert.NoError(t, err)
+
+	mongo := result.Redact(plain).(map[string]interface{})["Mongo"].(map[string]interface{})
+	assert.Equal(t, "mongodb://shared", mongo["URI"])
+}
+
+func TestRedact_MasksMapValues(t *testing.T) {
+	type credentials struct {
+		User     string
+		Password string `secret:"true"`
+	}
+
+	settings := struct {
+		Tokens  map[string]string `secret:"true"`
+		Brokers map[string]credentials
+	}{
+		Tokens:  map[string]string{"billing": "t0k3n"},
+		Brokers: map[string]credentials{"main": {User: "guest", Password: "hunter2"}},
+	}
+
+	redacted := config.Redact(settings).(map[string]interface{})
+
+	assert.Equal(t, map[string]interface{}{"billing": config.Redacted}, redacted["Tokens"])
+	assert.Equal(t, map[string]interface{}{
+		"main": map[string]interface{}{"User": "guest", "Password": config.Redacted},
+	}, redacted["Brokers"])
+}
+
+func TestRedact_SkipsLiveObjects(t *testing.T) {
+	settings := &appSettings{}
+	settings.Host = ":8080"
+	settings.Logger = logging.NewLogger()
+	settings.MetricsRegisterer = prometheus.NewRegistry()
+
+	redacted := config.Redact(settings).(map[string]interface{})
+
+	assert.Equal(t, ":8080", redacted["Host"])
+
+	for _, field := range []string{"Logger", "Tracer", "MetricsRegisterer", "MetricsGatherer"} {
+		assert.NotContains(t, redacted, field)
+	}
+
+	authorization := redacted["Authorization"].(map[string]interface{})
+	assert.NotContains(t, authorization, "Services")
+	assert.NotContains(t, authorization, "Registerer")
+}
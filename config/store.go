@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/getmilly/grok/logging"
+)
+
+//Subscriber is notified with the new settings after a successful reload.
+type Subscriber func(settings interface{})
+
+//Store holds the current settings and reloads them when their files change.
+type Store struct {
+	create  func() interface{}
+	options []Option
+
+	mu          sync.RWMutex
+	current     interface{}
+	paths       []string
+	modTimes    map[string]time.Time
+	subscribers []Subscriber
+
+	stop chan struct{}
+	once sync.Once
+}
+
+//NewStore loads settings into the value returned by create, which must be a pointer to struct.
+//Every reload creates a new value, so the current one is never partially updated.
+func NewStore(create func() interface{}, options ...Option) (*Store, error) {
+	store := &Store{
+		create:  create,
+		options: options,
+		stop:    make(chan struct{}),
+	}
+
+	settings, result, err := store.load()
+
+	if err != nil {
+		return nil, err
+	}
+
+	store.current = settings
+	store.paths = result.Paths
+	store.modTimes = modTimes(result.Paths)
+
+	return store, nil
+}
+
+//Current returns the last valid settings.
+func (store *Store) Current() interface{} {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return store.current
+}
+
+//Subscribe registers fn to be called after each successful reload.
+func (store *Store) Subscribe(fn Subscriber) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.subscribers = append(store.subscribers, fn)
+}
+
+//Reload loads settings from every source again.
+//Invalid settings are logged and rejected, keeping the current ones.
+func (store *Store) Reload() error {
+	settings, result, err := store.load()
+
+	if err != nil {
+		logging.LogWith(err).Error("settings reload rejected")
+		return err
+	}
+
+	store.mu.Lock()
+	store.current = settings
+	store.paths = result.Paths
+	store.modTimes = modTimes(result.Paths)
+	subscribers := append([]Subscriber(nil), store.subscribers...)
+	store.mu.Unlock()
+
+	logging.LogWith(result.Redact(settings)).Info("settings reloaded")
+
+	for _, fn := range subscribers {
+		fn(settings)
+	}
+
+	return nil
+}
+
+//Watch checks settings files for changes every interval and reloads them until Close.
+func (store *Store) Watch(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-store.stop:
+				return
+			case <-ticker.C:
+				if store.changed() {
+					store.Reload()
+				}
+			}
+		}
+	}()
+}
+
+//Close stops watching files.
+func (store *Store) Close() error {
+	store.once.Do(func() {
+		close(store.stop)
+	})
+
+	return nil
+}
+
+func (store *Store) load() (interface{}, *Result, error) {
+	settings := store.create()
+	result, err := Load(settings, store.options...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return settings, result, nil
+}
+
+func (store *Store) changed() bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	current := modTimes(store.paths)
+
+	for path, modTime := range current {
+		if !modTime.Equal(store.modTimes[path]) {
+			store.modTimes = current
+			return true
+		}
+	}
+
+	return false
+}
+
+func modTimes(paths []string) map[string]time.Time {
+	times := make(map[string]time.Time, len(paths))
+
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			times[path] = info.ModTime()
+		}
+	}
+
+	return times
+}
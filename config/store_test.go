@@ -0,0 +1,92 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/config"
+)
+
+func newAppSettings() interface{} {
+	return &appSettings{}
+}
+
+func TestLoad_FlagsOverrideEnvironment(t *testing.T) {
+	settings := &appSettings{}
+
+	_, err := config.Load(settings,
+		env(map[string]string{"HOST": ":8080", "AUTHORIZE": "false", "MONGO_URI": "mongodb://env"}),
+		config.Flags([]string{"--host=:9090", "--mongo-uri", "mongodb://flag", "--h2c"}),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, ":9090", settings.Host)
+	assert.Equal(t, "mongodb://flag", settings.Mongo.URI)
+	assert.True(t, settings.H2C)
+}
+
+func TestStore_ReloadsValidSettingsOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, ".env")
+	ioutil.WriteFile(file, []byte("HOST=:8080\nAUTHORIZE=false\nMONGO_URI=mongodb://a\nWORKERS=2\n"), 0600)
+
+	store, err := config.NewStore(newAppSettings, config.DotEnv(file), env(nil))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	notified := make(chan *appSettings, 1)
+
+	store.Subscribe(func(settings interface{}) {
+		notified <- settings.(*appSettings)
+	})
+
+	ioutil.WriteFile(file, []byte("HOST=:8080\nAUTHORIZE=false\nWORKERS=many\n"), 0600)
+
+	assert.Error(t, store.Reload())
+	assert.Equal(t, 2, store.Current().(*appSettings).Workers)
+	assert.Len(t, notified, 0)
+
+	ioutil.WriteFile(file, []byte("HOST=:8080\nAUTHORIZE=false\nMONGO_URI=mongodb://a\nWORKERS=6\n"), 0600)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(file, future, future)
+
+	store.Watch(10 * time.Millisecond)
+
+	select {
+	case settings := <-notified:
+		assert.Equal(t, 6, settings.Workers)
+		assert.Equal(t, settings, store.Current())
+	case <-time.After(2 * time.Second):
+		t.Fatal("reload not notified")
+	}
+}
+
+func TestStore_ReloadsWithoutOptionalDotEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	wd, _ := os.Getwd()
+	assert.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	store, err := config.NewStore(newAppSettings, config.DotEnv(), env(map[string]string{
+		"HOST":      ":8080",
+		"AUTHORIZE": "false",
+		"MONGO_URI": "mongodb://env",
+	}))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Reload())
+	assert.NoError(t, store.Reload())
+	assert.Equal(t, "mongodb://env", store.Current().(*appSettings).Mongo.URI)
+}
@@ -0,0 +1,236 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/getmilly/grok/logging"
+)
+
+//Shutdown stages, run in ascending order.
+const (
+	//StageServers stops inbound traffic like HTTP servers.
+	StageServers = 10
+	//StageConsumers drains message subscriptions.
+	StageConsumers = 20
+	//StageResources closes connections like databases and brokers.
+	StageResources = 30
+	//StageLogging flushes and closes loggers, after every other stage logged its shutdown.
+	StageLogging = 40
+)
+
+//Hook is called during shutdown, it must return before ctx is done.
+type Hook func(ctx context.Context) error
+
+//Options configures a Manager.
+type Options struct {
+	//Signals that trigger shutdown, defaults to SIGINT and SIGTERM.
+	Signals []os.Signal
+	//DrainDelay is how long to wait after readiness fails before running hooks.
+	DrainDelay time.Duration
+	//StageTimeout bounds each stage, defaults to 5 seconds.
+	StageTimeout time.Duration
+}
+
+//Errors aggregates errors returned by shutdown hooks.
+type Errors []error
+
+func (errs Errors) Error() string {
+	messages := make([]string, len(errs))
+
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+//Manager owns signal handling and coordinates process shutdown.
+type Manager struct {
+	options Options
+
+	mu     sync.Mutex
+	hooks  map[int][]namedHook
+	errors Errors
+
+	stopping int32
+	listen   sync.Once
+	once     sync.Once
+	done     chan struct{}
+}
+
+type namedHook struct {
+	name string
+	hook Hook
+}
+
+//New creates a lifecycle manager.
+func New(options Options) *Manager {
+	if len(options.Signals) == 0 {
+		options.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	if options.StageTimeout <= 0 {
+		options.StageTimeout = 5 * time.Second
+	}
+
+	return &Manager{
+		options: options,
+		hooks:   make(map[int][]namedHook),
+		done:    make(chan struct{}),
+	}
+}
+
+//Register adds a hook to a shutdown stage.
+//Hooks in the same stage run concurrently.
+func (m *Manager) Register(stage int, name string, hook Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hooks[stage] = append(m.hooks[stage], namedHook{name: name, hook: hook})
+}
+
+//OnShutdown adds a hook to StageResources.
+func (m *Manager) OnShutdown(name string, hook Hook) {
+	m.Register(StageResources, name, hook)
+}
+
+//ShuttingDown reports whether shutdown has started.
+func (m *Manager) ShuttingDown() bool {
+	return atomic.LoadInt32(&m.stopping) == 1
+}
+
+//Done is closed when shutdown completes.
+func (m *Manager) Done() <-chan struct{} {
+	return m.done
+}
+
+//Fail records err and starts shutdown.
+func (m *Manager) Fail(err error) {
+	m.addError(err)
+	go m.Shutdown()
+}
+
+//Wait listens for signals and blocks until shutdown completes.
+func (m *Manager) Wait() error {
+	m.listen.Do(func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, m.options.Signals...)
+
+		go func() {
+			defer signal.Stop(sigs)
+
+			select {
+			case sig := <-sigs:
+				logging.LogInfo("caught sig: %+v", sig)
+				m.Shutdown()
+			case <-m.done:
+			}
+		}()
+	})
+
+	<-m.done
+
+	return m.err()
+}
+
+//Shutdown runs every stage once and returns the aggregated hook errors.
+func (m *Manager) Shutdown() error {
+	m.once.Do(func() {
+		atomic.StoreInt32(&m.stopping, 1)
+
+		if m.options.DrainDelay > 0 {
+			logging.LogInfo("waiting %s before draining", m.options.DrainDelay)
+			time.Sleep(m.options.DrainDelay)
+		}
+
+		for _, stage := range m.stages() {
+			m.runStage(stage)
+		}
+
+		close(m.done)
+	})
+
+	<-m.done
+
+	return m.err()
+}
+
+func (m *Manager) stages() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stages []int
+
+	for stage := range m.hooks {
+		stages = append(stages, stage)
+	}
+
+	sort.Ints(stages)
+
+	return stages
+}
+
+func (m *Manager) runStage(stage int) {
+	m.mu.Lock()
+	hooks := m.hooks[stage]
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.options.StageTimeout)
+	defer cancel()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(len(hooks))
+
+	for _, h := range hooks {
+		go func(h namedHook) {
+			defer wg.Done()
+
+			logging.LogInfo("shutting down %s", h.name)
+
+			if err := h.hook(ctx); err != nil {
+				logging.LogWith(err).Error("shutdown error on %s", h.name)
+				m.addError(err)
+			}
+		}(h)
+	}
+
+	finished := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		m.addError(fmt.Errorf("shutdown stage %d: %v", stage, ctx.Err()))
+	}
+}
+
+func (m *Manager) addError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.errors = append(m.errors, err)
+}
+
+func (m *Manager) err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.errors) == 0 {
+		return nil
+	}
+
+	return m.errors
+}
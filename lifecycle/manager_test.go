@@ -0,0 +1,61 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/lifecycle"
+)
+
+func TestManager_RunsStagesInOrder(t *testing.T) {
+	manager := lifecycle.New(lifecycle.Options{})
+
+	var mu sync.Mutex
+	var calls []string
+
+	record := func(name string) lifecycle.Hook {
+		return func(ctx context.Context) error {
+			assert.True(t, manager.ShuttingDown())
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	manager.OnShutdown("mongo", record("mongo"))
+	manager.Register(lifecycle.StageConsumers, "subscriber", record("subscriber"))
+	manager.Register(lifecycle.StageServers, "http", record("http"))
+
+	assert.False(t, manager.ShuttingDown())
+	assert.NoError(t, manager.Shutdown())
+	assert.Equal(t, []string{"http", "subscriber", "mongo"}, calls)
+}
+
+func TestManager_FailReturnsErrorsFromWait(t *testing.T) {
+	manager := lifecycle.New(lifecycle.Options{StageTimeout: 10 * time.Millisecond})
+
+	manager.OnShutdown("broken", func(ctx context.Context) error {
+		return errors.New("close failed")
+	})
+	manager.OnShutdown("slow", func(ctx context.Context) error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+
+	manager.Fail(errors.New("listen failed"))
+
+	err := manager.Wait()
+
+	assert.Error(t, err)
+	assert.Len(t, err.(lifecycle.Errors), 3)
+	assert.Contains(t, err.Error(), "listen failed")
+	assert.Contains(t, err.Error(), "close failed")
+}
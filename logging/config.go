@@ -0,0 +1,225 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//FormatEnv sets the format of the default logger and of NewLogger.
+const FormatEnv = "LOG_FORMAT"
+
+//Format encodes entries.
+type Format string
+
+//Formats.
+const (
+	//FormatJSON writes one JSON object per line.
+	FormatJSON Format = "json"
+	//FormatLogfmt writes `key=value` pairs per line.
+	FormatLogfmt Format = "logfmt"
+	//FormatConsole writes colored, human-readable lines, for local development.
+	FormatConsole Format = "console"
+)
+
+//Config configures a logger built with New.
+type Config struct {
+	//Format of entries, FormatJSON when empty.
+	Format Format
+	//Level is the minimum level logged, see ParseLevel, debug when empty.
+	Level string
+	//Sinks receive every entry, os.Stdout when empty.
+	//Sinks implementing io.Closer are closed by Logger.Close.
+	Sinks []io.Writer
+	//Redactor masks field values, DefaultRedactionOptions when nil.
+	Redactor *Redactor
+}
+
+//ConfigFromEnv returns a config with Format and Level from LOG_FORMAT and LOG_LEVEL.
+func ConfigFromEnv() Config {
+	return Config{
+		Format: Format(strings.ToLower(os.Getenv(FormatEnv))),
+		Level:  os.Getenv(LevelEnv),
+	}
+}
+
+//New creates a logger writing to the configured sinks.
+func New(config Config) (*Logger, error) {
+	formatter, err := config.Format.formatter()
+
+	if err != nil {
+		return nil, err
+	}
+
+	level := DebugLevel
+
+	if config.Level != "" {
+		if level, err = ParseLevel(config.Level); err != nil {
+			return nil, err
+		}
+	}
+
+	sinks := config.Sinks
+
+	if len(sinks) == 0 {
+		sinks = []io.Writer{os.Stdout}
+	}
+
+	base := logrus.New()
+	base.Formatter = formatter
+	base.Out = multiWriter(sinks)
+
+	for _, sink := range sinks {
+		if memory, ok := sink.(*MemorySink); ok {
+			base.AddHook(memoryHook{memory})
+		}
+	}
+
+	l := newLogger(base)
+	l.sinks = sinks
+	l.setLevel(level)
+
+	if config.Redactor != nil {
+		l.SetRedactor(config.Redactor)
+	}
+
+	return l, nil
+}
+
+//Close flushes and closes the sinks implementing io.Closer, e.g. files and async writers.
+func (l *Logger) Close() error {
+	var first error
+
+	for _, sink := range l.sinks {
+		if closer, ok := sink.(io.Closer); ok && sink != os.Stdout && sink != os.Stderr {
+			if err := closer.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+
+	return first
+}
+
+func (format Format) formatter() (logrus.Formatter, error) {
+	switch format {
+	case "", FormatJSON:
+		return &logrus.JSONFormatter{}, nil
+	case FormatLogfmt:
+		return &logrus.TextFormatter{DisableColors: true, FullTimestamp: true, QuoteEmptyFields: true}, nil
+	case FormatConsole:
+		return &logrus.TextFormatter{ForceColors: true, FullTimestamp: true, TimestampFormat: time.StampMilli}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, use json, logfmt or console", format)
+	}
+}
+
+type fanout struct {
+	sinks []io.Writer
+}
+
+//multiWriter writes to every sink even if one fails, unlike io.MultiWriter.
+func multiWriter(sinks []io.Writer) io.Writer {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+
+	return &fanout{sinks: sinks}
+}
+
+func (f *fanout) Write(b []byte) (int, error) {
+	var first error
+
+	for _, sink := range f.sinks {
+		if _, err := sink.Write(b); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return len(b), first
+}
+
+//AsyncWriter writes to another writer in background so logging doesn't wait on slow sinks.
+//Entries are dropped when the buffer is full, see Dropped.
+type AsyncWriter struct {
+	dropped uint64
+	out     io.Writer
+	queue   chan []byte
+	done    chan struct{}
+	once    sync.Once
+	mu      sync.RWMutex
+	closed  bool
+	err     error
+}
+
+//Async buffers up to size entries written to out, 1024 when zero.
+func Async(out io.Writer, size int) *AsyncWriter {
+	if size <= 0 {
+		size = 1024
+	}
+
+	w := &AsyncWriter{
+		out:   out,
+		queue: make(chan []byte, size),
+		done:  make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+//Write queues a copy of b.
+func (w *AsyncWriter) Write(b []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	select {
+	case w.queue <- append([]byte(nil), b...):
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+	}
+
+	return len(b), nil
+}
+
+//Dropped returns the number of entries dropped because the buffer was full.
+func (w *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+//Close writes queued entries and closes out if it's an io.Closer.
+func (w *AsyncWriter) Close() error {
+	w.once.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		close(w.queue)
+		w.mu.Unlock()
+
+		<-w.done
+
+		if closer, ok := w.out.(io.Closer); ok && w.out != os.Stdout && w.out != os.Stderr {
+			w.err = closer.Close()
+		}
+	})
+
+	return w.err
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+
+	for b := range w.queue {
+		w.out.Write(b)
+	}
+}
@@ -0,0 +1,104 @@
+package logging_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/logging"
+)
+
+func TestNew_RejectsUnknownFormat(t *testing.T) {
+	_, err := logging.New(logging.Config{Format: "xml"})
+	assert.Error(t, err)
+
+	_, err = logging.New(logging.Config{Level: "verbose"})
+	assert.Error(t, err)
+}
+
+func TestNew_WritesLogfmtToEverySink(t *testing.T) {
+	first, second := &bytes.Buffer{}, &bytes.Buffer{}
+
+	logger, err := logging.New(logging.Config{
+		Format: logging.FormatLogfmt,
+		Sinks:  []io.Writer{first, second},
+	})
+	assert.NoError(t, err)
+
+	logger.WithField("order_id", 42).Info("order created")
+
+	assert.Contains(t, first.String(), `level=info msg="order created"`)
+	assert.Contains(t, first.String(), "order_id=42")
+	assert.Equal(t, first.String(), second.String())
+}
+
+func TestMemorySink_CapturesEntries(t *testing.T) {
+	sink := logging.NewMemorySink()
+
+	logger, err := logging.New(logging.Config{Level: "trace", Sinks: []io.Writer{sink}})
+	assert.NoError(t, err)
+
+	logger.WithField("order_id", 42).Trace("looking up order")
+	logger.With("payload").Warn("order %d is late", 42)
+
+	entries := sink.Entries()
+
+	assert.Len(t, entries, 2)
+	assert.Equal(t, logging.TraceLevel, entries[0].Level)
+	assert.Equal(t, 42, entries[0].Fields["order_id"])
+	assert.Equal(t, logging.WarnLevel, entries[1].Level)
+	assert.Equal(t, "order 42 is late", entries[1].Message)
+	assert.Equal(t, "payload", entries[1].Fields["data"])
+	assert.Contains(t, sink.String(), `"msg":"order 42 is late"`)
+
+	sink.Reset()
+	assert.Empty(t, sink.Entries())
+}
+
+func TestAsync_WritesQueuedEntriesOnClose(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	logger, err := logging.New(logging.Config{Sinks: []io.Writer{logging.Async(out, 16)}})
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		logger.WithField("i", i).Info("tick")
+	}
+
+	assert.NoError(t, logger.Close())
+	assert.Equal(t, 10, strings.Count(out.String(), "\n"))
+}
+
+func TestRotatingFile_RotatesAndKeepsBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logging")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logs", "worker.log")
+
+	file, err := logging.NewRotatingFile(logging.FileOptions{Path: path, MaxSize: 10, MaxBackups: 2})
+	assert.NoError(t, err)
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := file.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, file.Close())
+
+	current, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "fourth\n", string(current))
+
+	backups, err := file.Backups()
+	assert.NoError(t, err)
+	assert.Len(t, backups, 2)
+
+	previous, _ := ioutil.ReadFile(backups[1])
+	assert.Equal(t, "third\n", string(previous))
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"context"
+)
+
+//Correlation field names attached by api and nats to context entries.
+const (
+	RequestIDField = "request_id"
+	TraceIDField   = "trace_id"
+	SpanIDField    = "span_id"
+	SubjectField   = "subject"
+	RouteField     = "route"
+	MessageIDField = "message_id"
+)
+
+type contextKey struct{}
+
+//WithContext returns ctx carrying entry, returned by FromContext.
+func WithContext(ctx context.Context, entry *LogEntry) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+//FromContext returns the entry carried by ctx, e.g. with the request ID and trace IDs
+//of the request being served, or a new entry when ctx has none.
+func FromContext(ctx context.Context) *LogEntry {
+	if ctx != nil {
+		if entry, ok := ctx.Value(contextKey{}).(*LogEntry); ok {
+			return entry
+		}
+	}
+
+	return LogWith(nil)
+}
+
+//ContextWithFields returns ctx carrying its entry with fields added.
+func ContextWithFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	return WithContext(ctx, FromContext(ctx).WithFields(fields))
+}
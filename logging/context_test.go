@@ -0,0 +1,32 @@
+package logging_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/logging"
+)
+
+func TestFromContext_ReturnsBaseEntryWithoutContextEntry(t *testing.T) {
+	log := logging.FromContext(context.Background())
+
+	assert.NotNil(t, log)
+	assert.Nil(t, log.Field(logging.RequestIDField))
+}
+
+func TestContextWithFields_AccumulatesFields(t *testing.T) {
+	ctx := logging.ContextWithFields(context.Background(), map[string]interface{}{
+		logging.RequestIDField: "request-42",
+	})
+	ctx = logging.ContextWithFields(ctx, map[string]interface{}{
+		logging.SubjectField: "user-1",
+	})
+
+	log := logging.FromContext(ctx).With("payload")
+
+	assert.Equal(t, "request-42", log.Field(logging.RequestIDField))
+	assert.Equal(t, "user-1", log.Field(logging.SubjectField))
+	assert.Equal(t, "payload", log.Field("data"))
+}
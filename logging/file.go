@@ -0,0 +1,189 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const rotationSuffix = "20060102T150405.000000000"
+
+//FileOptions configures a RotatingFile.
+type FileOptions struct {
+	Path string
+	//MaxSize in bytes before the file is rotated, 100MB when zero.
+	MaxSize int64
+	//MaxAge deletes rotated files modified before, never when zero.
+	MaxAge time.Duration
+	//MaxBackups rotated files are kept, all when zero.
+	MaxBackups int
+}
+
+//RotatingFile is a sink appending to a file, renamed to `<path>.<timestamp>` when it reaches MaxSize.
+type RotatingFile struct {
+	options FileOptions
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+}
+
+//NewRotatingFile opens or creates the file, and its directory.
+func NewRotatingFile(options FileOptions) (*RotatingFile, error) {
+	if options.MaxSize <= 0 {
+		options.MaxSize = 100 << 20
+	}
+
+	f := &RotatingFile{options: options}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+//Write appends b, rotating first if b doesn't fit in MaxSize.
+func (f *RotatingFile) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.size > 0 && f.size+int64(len(b)) > f.options.MaxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(b)
+	f.size += int64(n)
+
+	return n, err
+}
+
+//Rotate renames the current file and starts a new one.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.rotate()
+}
+
+//Close closes the current file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.options.Path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.options.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+
+		f.file = nil
+	}
+
+	backup := f.options.Path + "." + time.Now().UTC().Format(rotationSuffix)
+
+	if err := os.Rename(f.options.Path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	return f.prune()
+}
+
+//prune deletes the rotated files beyond MaxBackups or older than MaxAge.
+func (f *RotatingFile) prune() error {
+	if f.options.MaxBackups <= 0 && f.options.MaxAge <= 0 {
+		return nil
+	}
+
+	backups, err := f.Backups()
+
+	if err != nil {
+		return err
+	}
+
+	for i, backup := range backups {
+		expired := f.options.MaxBackups > 0 && i < len(backups)-f.options.MaxBackups
+
+		if !expired && f.options.MaxAge > 0 {
+			if info, err := os.Stat(backup); err == nil {
+				expired = time.Since(info.ModTime()) > f.options.MaxAge
+			}
+		}
+
+		if expired {
+			if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+//Backups returns the rotated files, oldest first.
+func (f *RotatingFile) Backups() ([]string, error) {
+	matches, err := filepath.Glob(f.options.Path + ".*")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+
+	for _, match := range matches {
+		suffix := match[len(f.options.Path)+1:]
+
+		if _, err := time.Parse(rotationSuffix, suffix); err == nil {
+			backups = append(backups, match)
+		}
+	}
+
+	sort.Strings(backups)
+
+	return backups, nil
+}
@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+//Level is a minimum severity logged.
+type Level uint32
+
+//Levels, from the most verbose.
+const (
+	TraceLevel Level = iota
+	DebugLevel
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	FatalLevel
+)
+
+var levelNames = map[Level]string{
+	TraceLevel: "trace",
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warning",
+	ErrorLevel: "error",
+	FatalLevel: "fatal",
+}
+
+//ParseLevel parses trace, debug, info, warn or warning, error and fatal, ignoring case.
+func ParseLevel(level string) (Level, error) {
+	name := strings.ToLower(strings.TrimSpace(level))
+
+	if name == "warn" {
+		return WarnLevel, nil
+	}
+
+	for lvl, n := range levelNames {
+		if n == name {
+			return lvl, nil
+		}
+	}
+
+	return 0, fmt.Errorf("not a valid log level: %q", level)
+}
+
+func (level Level) String() string {
+	if name, ok := levelNames[level]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+//MarshalText encodes the level name, e.g. in JSON.
+func (level Level) MarshalText() ([]byte, error) {
+	return []byte(level.String()), nil
+}
+
+//UnmarshalText decodes a level name, see ParseLevel.
+func (level *Level) UnmarshalText(text []byte) error {
+	lvl, err := ParseLevel(string(text))
+
+	if err != nil {
+		return err
+	}
+
+	*level = lvl
+	return nil
+}
+
+func (level Level) logrus() logrus.Level {
+	switch level {
+	case TraceLevel, DebugLevel:
+		return logrus.DebugLevel
+	case InfoLevel:
+		return logrus.InfoLevel
+	case WarnLevel:
+		return logrus.WarnLevel
+	case ErrorLevel:
+		return logrus.ErrorLevel
+	default:
+		return logrus.FatalLevel
+	}
+}
+
+func fromLogrus(level logrus.Level) Level {
+	switch level {
+	case logrus.DebugLevel:
+		return DebugLevel
+	case logrus.InfoLevel:
+		return InfoLevel
+	case logrus.WarnLevel:
+		return WarnLevel
+	case logrus.ErrorLevel:
+		return ErrorLevel
+	default:
+		return FatalLevel
+	}
+}
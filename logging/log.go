@@ -1,41 +1,196 @@
 package logging
 
 import (
+	"io"
 	"os"
+	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
 )
 
-//Logger ...
+//LevelEnv sets the initial level of the default logger and of NewLogger.
+const LevelEnv = "LOG_LEVEL"
+
+//Logger writes structured entries with the hostname and application name.
+//It can be injected instead of using the package level functions, which use Default.
 type Logger struct {
-	applicationName string
+	base            *logrus.Logger
+	level           uint32
+	applicationName atomic.Value
+	hostname        string
+	sinks           []io.Writer
+	redactor        atomic.Value
 }
 
 //LogEntry ...
 type LogEntry struct {
-	entry *logrus.Entry
+	entry  *logrus.Entry
+	logger *Logger
 }
 
 var (
-	logger = NewLogger()
+	logger atomic.Value
 )
 
 func init() {
-	logrus.SetFormatter(&logrus.JSONFormatter{})
+	formatter, err := ConfigFromEnv().Format.formatter()
+
+	if err != nil {
+		formatter = &logrus.JSONFormatter{}
+	}
+
+	logrus.SetFormatter(formatter)
 
 	logrus.SetOutput(os.Stdout)
 
-	logrus.SetLevel(logrus.DebugLevel)
+	logger.Store(newLogger(logrus.StandardLogger()))
 }
 
-//NewLogger creates a new logger.
+//NewLogger creates a logger writing to stdout, configured by LOG_FORMAT and LOG_LEVEL,
+//with JSON and debug for missing or invalid values. See New for other sinks.
 func NewLogger() *Logger {
-	return &Logger{}
+	config := ConfigFromEnv()
+
+	if _, err := config.Format.formatter(); err != nil {
+		config.Format = FormatJSON
+	}
+
+	if !ValidLevel(config.Level) {
+		config.Level = ""
+	}
+
+	l, _ := New(config)
+	return l
+}
+
+func newLogger(base *logrus.Logger) *Logger {
+	hostname, _ := os.Hostname()
+
+	l := &Logger{base: base, hostname: hostname}
+	l.applicationName.Store("")
+	l.redactor.Store(defaultRedactor())
+	l.setLevel(DebugLevel)
+
+	if level, err := ParseLevel(os.Getenv(LevelEnv)); err == nil {
+		l.setLevel(level)
+	}
+
+	return l
+}
+
+//Default returns the logger used by the package level functions.
+func Default() *Logger {
+	return logger.Load().(*Logger)
+}
+
+//SetDefault replaces the logger used by the package level functions.
+func SetDefault(l *Logger) {
+	logger.Store(l)
 }
 
 //LogWithApplication configure logger to use specified app name.
 func LogWithApplication(appName string) {
-	logger.applicationName = appName
+	Default().SetApplicationName(appName)
+}
+
+//SetLevel changes the minimum level logged by the default logger, see ParseLevel.
+func SetLevel(level string) error {
+	return Default().SetLevel(level)
+}
+
+//ValidLevel reports whether level is accepted by SetLevel.
+func ValidLevel(level string) bool {
+	_, err := ParseLevel(level)
+	return err == nil
+}
+
+//SetApplicationName sets the application_name field of entries.
+func (l *Logger) SetApplicationName(name string) {
+	l.applicationName.Store(name)
+}
+
+//SetOutput changes where entries are written.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.base.SetOutput(w)
+}
+
+//SetLevel changes the minimum level logged, see ParseLevel.
+func (l *Logger) SetLevel(level string) error {
+	lvl, err := ParseLevel(level)
+
+	if err != nil {
+		return err
+	}
+
+	l.setLevel(lvl)
+	return nil
+}
+
+//SetRedactor replaces the redactor applied to every field value, see Redactor.Value.
+func (l *Logger) SetRedactor(r *Redactor) {
+	l.redactor.Store(r)
+}
+
+//Redactor returns the redactor applied to every field value.
+func (l *Logger) Redactor() *Redactor {
+	return l.redactor.Load().(*Redactor)
+}
+
+//Level returns the minimum level logged.
+func (l *Logger) Level() Level {
+	return Level(atomic.LoadUint32(&l.level))
+}
+
+func (l *Logger) setLevel(level Level) {
+	atomic.StoreUint32(&l.level, uint32(level))
+	l.base.SetLevel(level.logrus())
+}
+
+//With returns an entry with data under the `data` field.
+//Field values are redacted, see SetRedactor.
+func (l *Logger) With(data interface{}) *LogEntry {
+	return l.WithFields(logrus.Fields{"data": data})
+}
+
+//WithField returns an entry with a top level field.
+func (l *Logger) WithField(key string, value interface{}) *LogEntry {
+	return l.WithFields(logrus.Fields{key: value})
+}
+
+//WithFields returns an entry with top level fields.
+func (l *Logger) WithFields(fields map[string]interface{}) *LogEntry {
+	entry := l.base.WithFields(logrus.Fields{
+		"hostname":         l.hostname,
+		"application_name": l.applicationName.Load(),
+	})
+
+	return &LogEntry{entry: entry.WithFields(l.redact(fields)), logger: l}
+}
+
+func (l *Logger) redact(fields map[string]interface{}) logrus.Fields {
+	redactor := l.Redactor()
+	redacted := make(logrus.Fields, len(fields))
+
+	for key, value := range fields {
+		redacted[key] = redactor.field(value)
+	}
+
+	return redacted
+}
+
+//WithError returns an entry with err under the `error` field.
+func (l *Logger) WithError(err error) *LogEntry {
+	return l.WithFields(nil).WithError(err)
+}
+
+//LogTrace logs an event
+func LogTrace(message string, args ...interface{}) {
+	LogWith(nil).Trace(message, args...)
+}
+
+//LogDebug logs an event
+func LogDebug(message string, args ...interface{}) {
+	LogWith(nil).Debug(message, args...)
 }
 
 //LogWarn logs an event
@@ -53,6 +208,49 @@ func LogError(message string, args ...interface{}) {
 	LogWith(nil).Error(message, args...)
 }
 
+//With returns a copy of the entry with data, like LogWith.
+func (e *LogEntry) With(data interface{}) *LogEntry {
+	return e.WithField("data", data)
+}
+
+//WithPayload returns a copy of the entry with data, like With, also masking the redaction patterns.
+//Use it for bodies and messages, see Redactor.Value.
+func (e *LogEntry) WithPayload(data interface{}) *LogEntry {
+	return e.With(e.logger.Redactor().Value(data))
+}
+
+//WithFields returns a copy of the entry with fields added at the top level.
+func (e *LogEntry) WithFields(fields map[string]interface{}) *LogEntry {
+	return &LogEntry{entry: e.entry.WithFields(e.logger.redact(fields)), logger: e.logger}
+}
+
+//Field returns the value of a top level field, nil if not set.
+func (e *LogEntry) Field(key string) interface{} {
+	return e.entry.Data[key]
+}
+
+//WithField returns a copy of the entry with a top level field.
+func (e *LogEntry) WithField(key string, value interface{}) *LogEntry {
+	return e.WithFields(map[string]interface{}{key: value})
+}
+
+//WithError returns a copy of the entry with err under the `error` field.
+func (e *LogEntry) WithError(err error) *LogEntry {
+	return e.WithField(logrus.ErrorKey, err)
+}
+
+//Trace logs an event, as debug with `severity` trace since logrus has no trace level.
+func (e *LogEntry) Trace(message string, args ...interface{}) {
+	if e.logger.Level() <= TraceLevel {
+		e.entry.WithField("severity", TraceLevel.String()).Debugf(message, args...)
+	}
+}
+
+//Debug logs an event
+func (e *LogEntry) Debug(message string, args ...interface{}) {
+	e.entry.Debugf(message, args...)
+}
+
 //Warn logs an event
 func (e *LogEntry) Warn(message string, args ...interface{}) {
 	e.entry.Warnf(message, args...)
@@ -68,21 +266,12 @@ func (e *LogEntry) Error(message string, args ...interface{}) {
 	e.entry.Errorf(message, args...)
 }
 
-//LogWith ...
-func LogWith(data interface{}) *LogEntry {
-	fields := buildData(data)
-	entry := logrus.WithFields(fields)
-	return &LogEntry{
-		entry: entry,
-	}
+//Fatal logs an error and exits with status 1.
+func (e *LogEntry) Fatal(message string, args ...interface{}) {
+	e.entry.Fatalf(message, args...)
 }
 
-func buildData(data interface{}) logrus.Fields {
-	fields := make(logrus.Fields)
-
-	fields["data"] = data
-	fields["hostname"], _ = os.Hostname()
-	fields["application_name"] = logger.applicationName
-
-	return fields
+//LogWith ...
+func LogWith(data interface{}) *LogEntry {
+	return Default().With(data)
 }
@@ -0,0 +1,67 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/logging"
+)
+
+func TestParseLevel(t *testing.T) {
+	level, err := logging.ParseLevel("WARN")
+	assert.NoError(t, err)
+	assert.Equal(t, logging.WarnLevel, level)
+
+	level, err = logging.ParseLevel("trace")
+	assert.NoError(t, err)
+	assert.Equal(t, logging.TraceLevel, level)
+
+	_, err = logging.ParseLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestLogger_WritesStructuredFields(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	logger := logging.NewLogger()
+	logger.SetOutput(out)
+	logger.SetApplicationName("orders")
+
+	logger.WithField("order_id", 42).WithError(errors.New("out of stock")).Error("order failed")
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+
+	assert.Equal(t, "error", entry["level"])
+	assert.Equal(t, "order failed", entry["msg"])
+	assert.Equal(t, float64(42), entry["order_id"])
+	assert.Equal(t, "out of stock", entry["error"])
+	assert.Equal(t, "orders", entry["application_name"])
+	assert.NotContains(t, entry, "data")
+}
+
+func TestLogger_FiltersByLevel(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	logger := logging.NewLogger()
+	logger.SetOutput(out)
+
+	assert.NoError(t, logger.SetLevel("debug"))
+	logger.WithFields(nil).Trace("hidden")
+	logger.WithFields(nil).Debug("shown")
+	assert.Equal(t, 1, bytes.Count(out.Bytes(), []byte("\n")))
+
+	out.Reset()
+	assert.NoError(t, logger.SetLevel("trace"))
+	logger.WithFields(nil).Trace("shown")
+	assert.Contains(t, out.String(), `"severity":"trace"`)
+
+	out.Reset()
+	assert.NoError(t, logger.SetLevel("warning"))
+	logger.WithFields(nil).Info("hidden")
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//Entry is an entry captured by a MemorySink.
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Fields  map[string]interface{}
+}
+
+//MemorySink keeps entries in memory, for tests.
+//Add it to Config.Sinks to capture both the entries and their formatted output.
+type MemorySink struct {
+	mu      sync.Mutex
+	entries []Entry
+	output  bytes.Buffer
+}
+
+type memoryHook struct {
+	sink *MemorySink
+}
+
+//NewMemorySink creates an empty sink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+//Write keeps formatted output, see String.
+func (sink *MemorySink) Write(b []byte) (int, error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return sink.output.Write(b)
+}
+
+//Entries returns the entries logged so far, in order.
+func (sink *MemorySink) Entries() []Entry {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return append([]Entry{}, sink.entries...)
+}
+
+//String returns the formatted output so far.
+func (sink *MemorySink) String() string {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return sink.output.String()
+}
+
+//Reset drops captured entries and output.
+func (sink *MemorySink) Reset() {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	sink.entries = nil
+	sink.output.Reset()
+}
+
+func (hook memoryHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (hook memoryHook) Fire(entry *logrus.Entry) error {
+	fields := make(map[string]interface{}, len(entry.Data))
+
+	for key, value := range entry.Data {
+		fields[key] = value
+	}
+
+	level := fromLogrus(entry.Level)
+
+	if fields["severity"] == TraceLevel.String() {
+		level = TraceLevel
+	}
+
+	hook.sink.mu.Lock()
+	defer hook.sink.mu.Unlock()
+
+	hook.sink.entries = append(hook.sink.entries, Entry{
+		Time:    entry.Time,
+		Level:   level,
+		Message: entry.Message,
+		Fields:  fields,
+	})
+
+	return nil
+}
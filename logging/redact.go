@@ -0,0 +1,372 @@
+package logging
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Mask replaces redacted values.
+const Mask = "[REDACTED]"
+
+const maxRedactDepth = 32
+
+var (
+	//DefaultRedactedHeaders carry credentials.
+	DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+	//DefaultRedactedPaths are masked in payloads.
+	DefaultRedactedPaths = []string{"$.password"}
+
+	//EmailPattern matches email addresses.
+	EmailPattern = regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)
+	//CPFPattern matches formatted brazilian CPF numbers, e.g. `123.456.789-09`.
+	CPFPattern = regexp.MustCompile(`\b\d{3}\.\d{3}\.\d{3}-\d{2}\b`)
+	//DefaultRedactedPatterns are masked in payloads, see Redactor.Value.
+	DefaultRedactedPatterns = []*regexp.Regexp{EmailPattern, CPFPattern}
+)
+
+//RedactionOptions configures a Redactor.
+type RedactionOptions struct {
+	//Headers masked by Redactor.Headers, case insensitive.
+	Headers []string
+	//Paths masked in logged values, e.g. `$.password`, `$.card.number` or `$.items[*].cpf`.
+	//Paths are rooted at the value logged, e.g. the request body or the NATS message data.
+	Paths []string
+	//Patterns masked in payload strings, e.g. EmailPattern, see Redactor.Value.
+	Patterns []*regexp.Regexp
+}
+
+//Redactor masks sensitive data before it's logged.
+//Struct fields tagged `secret:"true"` are always masked.
+type Redactor struct {
+	headers  map[string]bool
+	paths    [][]string
+	patterns []*regexp.Regexp
+}
+
+//DefaultRedactionOptions masks DefaultRedactedHeaders, DefaultRedactedPaths and DefaultRedactedPatterns.
+func DefaultRedactionOptions() RedactionOptions {
+	return RedactionOptions{
+		Headers:  DefaultRedactedHeaders,
+		Paths:    DefaultRedactedPaths,
+		Patterns: DefaultRedactedPatterns,
+	}
+}
+
+func defaultRedactor() *Redactor {
+	r, err := NewRedactor(DefaultRedactionOptions())
+
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+//NewRedactor creates a redactor, failing on malformed paths.
+func NewRedactor(options RedactionOptions) (*Redactor, error) {
+	r := &Redactor{
+		headers:  make(map[string]bool),
+		patterns: options.Patterns,
+	}
+
+	for _, header := range options.Headers {
+		r.headers[http.CanonicalHeaderKey(header)] = true
+	}
+
+	for _, path := range options.Paths {
+		segments, err := parsePath(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.paths = append(r.paths, segments)
+	}
+
+	return r, nil
+}
+
+//Headers returns a copy of h with denied headers masked.
+func (r *Redactor) Headers(h http.Header) http.Header {
+	redacted := make(http.Header, len(h))
+
+	for key, values := range h {
+		if r.headers[http.CanonicalHeaderKey(key)] {
+			redacted[key] = []string{Mask}
+			continue
+		}
+
+		redacted[key] = append([]string(nil), values...)
+	}
+
+	return redacted
+}
+
+//Value returns v as maps, slices and scalars with secret fields, paths and patterns masked.
+//Errors are logged as their message. Use it for payloads, e.g. bodies and messages, see LogEntry.WithPayload.
+func (r *Redactor) Value(v interface{}) interface{} {
+	if r == nil {
+		return v
+	}
+
+	generic := r.walk(reflect.ValueOf(v), 0)
+
+	for _, path := range r.paths {
+		generic = maskPath(generic, path)
+	}
+
+	return generic
+}
+
+//field masks secret fields and paths of every logged field, but not patterns,
+//which only apply to payloads. Scalars are returned as is.
+func (r *Redactor) field(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil, string, bool, int, int32, int64, uint, uint32, uint64, float32, float64, time.Time, time.Duration:
+		return v
+	case error:
+		return value.Error()
+	}
+
+	if r == nil {
+		return v
+	}
+
+	fields := *r
+	fields.patterns = nil
+
+	return fields.Value(v)
+}
+
+func (r *Redactor) walk(v reflect.Value, depth int) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	if depth > maxRedactDepth {
+		return fmt.Sprintf("%v", v)
+	}
+
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+	}
+
+	if v.CanInterface() {
+		switch value := v.Interface().(type) {
+		case error:
+			return r.mask(value.Error())
+		case json.Marshaler, encoding.TextMarshaler:
+			return r.walkJSON(value, depth)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return r.walk(v.Elem(), depth+1)
+	case reflect.String:
+		return r.mask(v.String())
+	case reflect.Struct:
+		fields := make(map[string]interface{})
+		r.walkStruct(v, fields, depth)
+		return fields
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+
+		entries := make(map[string]interface{}, v.Len())
+
+		for _, key := range v.MapKeys() {
+			entries[fmt.Sprint(key.Interface())] = r.walk(v.MapIndex(key), depth+1)
+		}
+
+		return entries
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return r.walkJSON(v.Interface(), depth)
+		}
+
+		items := make([]interface{}, v.Len())
+
+		for i := range items {
+			items[i] = r.walk(v.Index(i), depth+1)
+		}
+
+		return items
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil
+	default:
+		if v.CanInterface() {
+			return v.Interface()
+		}
+
+		return nil
+	}
+}
+
+func (r *Redactor) walkStruct(v reflect.Value, fields map[string]interface{}, depth int) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name, _ := jsonName(field)
+
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := v.Field(i)
+
+			if embedded.Kind() == reflect.Ptr {
+				if embedded.IsNil() {
+					continue
+				}
+
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				r.walkStruct(embedded, fields, depth+1)
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if field.Tag.Get("secret") == "true" {
+			fields[name] = Mask
+			continue
+		}
+
+		fields[name] = r.walk(v.Field(i), depth+1)
+	}
+}
+
+func (r *Redactor) walkJSON(v interface{}, depth int) interface{} {
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return r.mask(fmt.Sprint(v))
+	}
+
+	var generic interface{}
+
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil
+	}
+
+	return r.walk(reflect.ValueOf(generic), depth+1)
+}
+
+func (r *Redactor) mask(s string) string {
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, Mask)
+	}
+
+	return s
+}
+
+func jsonName(field reflect.StructField) (string, bool) {
+	tag, ok := field.Tag.Lookup("json")
+
+	if !ok {
+		return "", false
+	}
+
+	return strings.Split(tag, ",")[0], true
+}
+
+//parsePath splits `$.a.b[*].c` into `a`, `b`, `*`, `c`.
+func parsePath(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "$.") {
+		return nil, fmt.Errorf("redaction path %q must start with `$.`", path)
+	}
+
+	var segments []string
+
+	for _, part := range strings.Split(path[2:], ".") {
+		name := part
+		var indexes []string
+
+		if open := strings.Index(part, "["); open >= 0 {
+			if !strings.HasSuffix(part, "]") {
+				return nil, fmt.Errorf("redaction path %q is malformed", path)
+			}
+
+			name = part[:open]
+			indexes = strings.Split(part[open+1:len(part)-1], "][")
+		}
+
+		if name == "" && len(indexes) == 0 {
+			return nil, fmt.Errorf("redaction path %q has an empty segment", path)
+		}
+
+		if name != "" {
+			segments = append(segments, name)
+		}
+
+		for _, index := range indexes {
+			if _, err := strconv.Atoi(index); err != nil && index != "*" {
+				return nil, fmt.Errorf("redaction path %q has an invalid index %q", path, index)
+			}
+
+			segments = append(segments, index)
+		}
+	}
+
+	return segments, nil
+}
+
+func maskPath(node interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		if node == nil {
+			return nil
+		}
+
+		return Mask
+	}
+
+	segment, rest := path[0], path[1:]
+
+	switch value := node.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if segment == "*" || segment == key {
+				value[key] = maskPath(child, rest)
+			}
+		}
+	case []interface{}:
+		for i, child := range value {
+			if segment == "*" || segment == strconv.Itoa(i) {
+				value[i] = maskPath(child, rest)
+			}
+		}
+	}
+
+	return node
+}
@@ -0,0 +1,101 @@
+package logging_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/logging"
+)
+
+type card struct {
+	Number string `json:"number"`
+	Holder string `json:"holder"`
+}
+
+type payment struct {
+	Token  string `json:"token" secret:"true"`
+	Email  string `json:"email"`
+	Card   card   `json:"card"`
+	Amount int    `json:"amount"`
+	Notes  string `json:"-"`
+}
+
+func TestRedactor_MasksTagsPathsAndPatterns(t *testing.T) {
+	options := logging.DefaultRedactionOptions()
+	options.Paths = append(options.Paths, "$.card.number", "$.items[*].cpf")
+
+	redactor, err := logging.NewRedactor(options)
+	assert.NoError(t, err)
+
+	value := redactor.Value(&payment{
+		Token:  "tok_123",
+		Email:  "contact john.doe@example.com",
+		Card:   card{Number: "4111111111111111", Holder: "John"},
+		Amount: 10,
+		Notes:  "internal",
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"token":  logging.Mask,
+		"email":  "contact " + logging.Mask,
+		"card":   map[string]interface{}{"number": logging.Mask, "holder": "John"},
+		"amount": 10,
+	}, value)
+
+	value = redactor.Value(map[string]interface{}{
+		"password": "hunter2",
+		"items":    []interface{}{map[string]interface{}{"cpf": "x"}, map[string]interface{}{"cpf": "y"}},
+		"note":     "cpf 123.456.789-09 and 12345678909",
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"password": logging.Mask,
+		"items":    []interface{}{map[string]interface{}{"cpf": logging.Mask}, map[string]interface{}{"cpf": logging.Mask}},
+		"note":     "cpf " + logging.Mask + " and 12345678909",
+	}, value)
+
+	assert.Equal(t, "invalid "+logging.Mask, redactor.Value(errors.New("invalid a@b.io")))
+}
+
+func TestRedactor_MasksHeaders(t *testing.T) {
+	redactor, err := logging.NewRedactor(logging.RedactionOptions{Headers: []string{"authorization"}})
+	assert.NoError(t, err)
+
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer secret")
+	headers.Set("Accept", "application/json")
+
+	redacted := redactor.Headers(headers)
+
+	assert.Equal(t, logging.Mask, redacted.Get("Authorization"))
+	assert.Equal(t, "application/json", redacted.Get("Accept"))
+	assert.Equal(t, "Bearer secret", headers.Get("Authorization"))
+}
+
+func TestNewRedactor_RejectsMalformedPaths(t *testing.T) {
+	for _, path := range []string{"password", "$.", "$.items[", "$.items[x]"} {
+		_, err := logging.NewRedactor(logging.RedactionOptions{Paths: []string{path}})
+		assert.Error(t, err, path)
+	}
+}
+
+func TestLogger_RedactsFields(t *testing.T) {
+	sink := logging.NewMemorySink()
+
+	logger, err := logging.New(logging.Config{Sinks: []io.Writer{sink}})
+	assert.NoError(t, err)
+
+	logger.With(map[string]interface{}{"password": "hunter2"}).WithField("user", "a@b.io").Info("login")
+	logger.WithField("order", "123.456.789-09").WithPayload(map[string]interface{}{"email": "a@b.io"}).Info("order")
+
+	login, order := sink.Entries()[0], sink.Entries()[1]
+
+	assert.Equal(t, map[string]interface{}{"password": logging.Mask}, login.Fields["data"])
+	assert.Equal(t, "a@b.io", login.Fields["user"])
+	assert.Equal(t, "123.456.789-09", order.Fields["order"])
+	assert.Equal(t, map[string]interface{}{"email": logging.Mask}, order.Fields["data"])
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+//ErrAPIKeyNotFound is returned by API key stores when a key isn't stored.
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+//APIKey is a machine client credential stored hashed at rest.
+type APIKey struct {
+	ID        string    `json:"id" bson:"_id"`
+	Hash      string    `json:"hash" bson:"hash"`
+	Scopes    []string  `json:"scopes" bson:"scopes"`
+	Disabled  bool      `json:"disabled" bson:"disabled"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+}
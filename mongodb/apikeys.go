@@ -0,0 +1,56 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/getmilly/grok/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//DefaultAPIKeyLookupTimeout bounds API key lookups by default.
+const DefaultAPIKeyLookupTimeout = 5 * time.Second
+
+//APIKeyStoreOptions configures an APIKeyStore.
+type APIKeyStoreOptions struct {
+	//Timeout bounds each lookup, besides the request context, DefaultAPIKeyLookupTimeout when zero.
+	Timeout time.Duration
+}
+
+//APIKeyStore looks up API keys stored in a MongoDB collection.
+type APIKeyStore struct {
+	collection *mongo.Collection
+	options    APIKeyStoreOptions
+}
+
+//NewAPIKeyStore creates a store over the given database collection.
+func NewAPIKeyStore(client *mongo.Client, database, collection string, options APIKeyStoreOptions) *APIKeyStore {
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultAPIKeyLookupTimeout
+	}
+
+	return &APIKeyStore{
+		collection: client.Database(database).Collection(collection),
+		options:    options,
+	}
+}
+
+//FindByHash implements api.APIKeyStore, canceled with ctx.
+func (store *APIKeyStore) FindByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, store.options.Timeout)
+	defer cancel()
+
+	key := &models.APIKey{}
+	err := store.collection.FindOne(ctx, bson.M{"hash": hash}).Decode(key)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrAPIKeyNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
@@ -0,0 +1,49 @@
+package mongodb_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/api"
+	"github.com/getmilly/grok/mongodb"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestAPIKeyStore_FindByHash(t *testing.T) {
+	if os.Getenv("MONGODB_URL") == "" {
+		t.Skip("MONGODB_URL not set")
+	}
+
+	client, err := mongodb.Connect(os.Getenv("MONGODB_URL"))
+	assert.NoError(t, err)
+	defer client.Disconnect(context.Background())
+
+	collection := uuid.NewV4().String()
+	defer client.Database("grok_test").Collection(collection).Drop(context.Background())
+
+	_, err = client.Database("grok_test").Collection(collection).InsertOne(context.Background(), api.APIKey{
+		ID:     "batch",
+		Hash:   api.HashAPIKey("key"),
+		Scopes: []string{"orders:read"},
+	})
+	assert.NoError(t, err)
+
+	store := mongodb.NewAPIKeyStore(client, "grok_test", collection, mongodb.APIKeyStoreOptions{})
+
+	key, err := store.FindByHash(context.Background(), api.HashAPIKey("key"))
+	assert.NoError(t, err)
+	assert.Equal(t, "batch", key.ID)
+	assert.Equal(t, []string{"orders:read"}, key.Scopes)
+
+	_, err = store.FindByHash(context.Background(), api.HashAPIKey("unknown"))
+	assert.Equal(t, api.ErrAPIKeyNotFound, err)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = store.FindByHash(canceled, api.HashAPIKey("key"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,28 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+//HealthCheck pings the primary. It's an api.Check:
+//	healthz.AddReadinessCheck("mongodb", mongodb.HealthCheck(client), api.CheckOptions{Critical: true})
+func HealthCheck(client *mongo.Client) func(ctx context.Context) error {
+	return PingCheck(client, readpref.Primary())
+}
+
+//PingCheck pings a server selected by rp, failing when none is selectable before the check timeout.
+func PingCheck(client *mongo.Client, rp *readpref.ReadPref) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		err := client.Ping(ctx, rp)
+
+		if err != nil && ctx.Err() != nil {
+			return fmt.Errorf("server selection timed out: %v", err)
+		}
+
+		return err
+	}
+}
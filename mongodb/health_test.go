@@ -0,0 +1,44 @@
+package mongodb_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/getmilly/grok/mongodb"
+)
+
+func TestHealthCheck_FailsWithoutReachableServers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Connect(ctx))
+	defer client.Disconnect(ctx)
+
+	err = mongodb.HealthCheck(client)(ctx)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "server selection timed out")
+}
+
+func TestHealthCheck_PingsPrimary(t *testing.T) {
+	if os.Getenv("MONGODB_URL") == "" {
+		t.Skip("MONGODB_URL not set")
+	}
+
+	client, err := mongodb.Connect(os.Getenv("MONGODB_URL"))
+	assert.NoError(t, err)
+	defer client.Disconnect(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, mongodb.HealthCheck(client)(ctx))
+}
@@ -0,0 +1,84 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	nats "github.com/nats-io/go-nats"
+	"github.com/nats-io/go-nats-streaming"
+)
+
+var (
+	//ErrNotSubscribed is reported by Subscriber.HealthCheck before Run.
+	ErrNotSubscribed = errors.New("subscription not started")
+)
+
+var statuses = map[nats.Status]string{
+	nats.DISCONNECTED: "disconnected",
+	nats.CONNECTED:    "connected",
+	nats.CLOSED:       "closed",
+	nats.RECONNECTING: "reconnecting",
+	nats.CONNECTING:   "connecting",
+}
+
+//ConnectionCheck fails unless the connection is connected.
+//It's an api.Check:
+//	healthz.AddReadinessCheck("nats", nats.ConnectionCheck(conn.Conn), api.CheckOptions{Critical: true})
+func ConnectionCheck(conn *nats.Conn) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if conn == nil {
+			return errors.New("nats connection not set")
+		}
+
+		if status := conn.Status(); status != nats.CONNECTED {
+			return fmt.Errorf("nats connection %s", statuses[status])
+		}
+
+		return nil
+	}
+}
+
+//StreamingCheck fails unless the streaming connection is connected and answers a flush.
+func StreamingCheck(conn stan.Conn) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		nc := conn.NatsConn()
+
+		if nc == nil {
+			return errors.New("nats streaming connection closed")
+		}
+
+		if err := ConnectionCheck(nc)(ctx); err != nil {
+			return err
+		}
+
+		return nc.FlushWithContext(ctx)
+	}
+}
+
+//HealthCheck fails when the subscription isn't running or has more than maxPending messages waiting.
+func (subscriber *Subscriber) HealthCheck(maxPending int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		subscription := subscriber.current()
+
+		if subscription == nil {
+			return ErrNotSubscribed
+		}
+
+		if err := StreamingCheck(subscriber.conn)(ctx); err != nil {
+			return err
+		}
+
+		pending, _, err := subscription.Pending()
+
+		if err != nil {
+			return err
+		}
+
+		if pending > maxPending {
+			return fmt.Errorf("subscription %s lagging: %d messages pending", subscriber.subject, pending)
+		}
+
+		return nil
+	}
+}
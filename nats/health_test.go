@@ -0,0 +1,59 @@
+package nats_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	nats "github.com/nats-io/go-nats"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/lifecycle"
+	gnats "github.com/getmilly/grok/nats"
+)
+
+func TestConnectionCheck_FailsWhenNotConnected(t *testing.T) {
+	err := gnats.ConnectionCheck(&nats.Conn{})(context.Background())
+	assert.EqualError(t, err, "nats connection disconnected")
+
+	err = gnats.ConnectionCheck(nil)(context.Background())
+	assert.Error(t, err)
+}
+
+func TestSubscriberHealthCheck_FailsBeforeRun(t *testing.T) {
+	err := gnats.NewSubscriber(nil).HealthCheck(100)(context.Background())
+	assert.Equal(t, gnats.ErrNotSubscribed, err)
+}
+
+func (conn *fakeConn) NatsConn() *nats.Conn {
+	return nil
+}
+
+func TestSubscriberHealthCheck_RunsConcurrentlyWithRun(t *testing.T) {
+	conn := &fakeConn{subscribed: make(chan struct{})}
+	subscriber := gnats.NewSubscriber(conn).
+		WithSubject("orders").
+		WithQueue("billing").
+		WithMessageType(reflect.TypeOf(Testing{})).
+		WithLifecycle(lifecycle.New(lifecycle.Options{})).
+		WithMetrics(gnats.NewMetrics(prometheus.NewRegistry())).
+		WithHandler(func(m interface{}) error {
+			return nil
+		})
+
+	check := subscriber.HealthCheck(100)
+
+	go subscriber.Run()
+
+	deadline := time.Now().Add(time.Second)
+
+	for check(context.Background()) == gnats.ErrNotSubscribed {
+		if time.Now().After(deadline) {
+			t.Fatal("not subscribed")
+		}
+	}
+
+	assert.EqualError(t, check(context.Background()), "nats streaming connection closed")
+}
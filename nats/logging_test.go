@@ -0,0 +1,63 @@
+package nats_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/lifecycle"
+	"github.com/getmilly/grok/logging"
+	gnats "github.com/getmilly/grok/nats"
+	"github.com/nats-io/go-nats-streaming"
+	"github.com/nats-io/go-nats-streaming/pb"
+)
+
+func TestLogging_RedactsAndCorrelatesMessages(t *testing.T) {
+	sink := logging.NewMemorySink()
+	logger, _ := logging.New(logging.Config{Sinks: []io.Writer{sink}})
+
+	conn := &fakeConn{subscribed: make(chan struct{})}
+	manager := lifecycle.New(lifecycle.Options{})
+
+	go gnats.NewSubscriber(conn).
+		WithSubject("signups").
+		WithQueue("mailer").
+		WithMessageType(reflect.TypeOf(Testing{})).
+		WithLifecycle(manager).
+		WithMetrics(gnats.NewMetrics(prometheus.NewRegistry())).
+		WithLogger(logger).
+		WithContextHandler(func(ctx context.Context, m interface{}) error {
+			logging.FromContext(ctx).Info("handled")
+			return context.Canceled
+		}).
+		Run()
+
+	select {
+	case <-conn.subscribed:
+	case <-time.After(time.Second):
+		t.Fatal("not subscribed")
+	}
+
+	message, _ := gnats.NewMessage(Testing{Value: "john@example.com"})
+	message.Metadata = map[string]interface{}{logging.RequestIDField: "request-42"}
+
+	data, _ := json.Marshal(message)
+	conn.handler(&stan.Msg{MsgProto: pb.MsgProto{Data: data}})
+
+	manager.Shutdown()
+
+	entries := sink.Entries()
+
+	assert.Len(t, entries, 3)
+	assert.Equal(t, map[string]interface{}{"Value": logging.Mask}, entries[0].Fields["data"])
+	assert.Equal(t, "handled", entries[1].Message)
+	assert.Equal(t, "request-42", entries[1].Fields[logging.RequestIDField])
+	assert.Equal(t, message.ID, entries[1].Fields[logging.MessageIDField])
+	assert.NotContains(t, sink.String(), "john@example.com")
+}
@@ -0,0 +1,87 @@
+package nats
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Metrics are the Prometheus collectors of subscribers and producers.
+type Metrics struct {
+	Received        *prometheus.CounterVec
+	Handled         *prometheus.CounterVec
+	Failed          *prometheus.CounterVec
+	Unacked         *prometheus.CounterVec
+	DecodeErrors    *prometheus.CounterVec
+	HandlerLatency  *prometheus.HistogramVec
+	Published       *prometheus.CounterVec
+	PublishFailures *prometheus.CounterVec
+}
+
+var (
+	defaultMetrics     *Metrics
+	defaultMetricsOnce sync.Once
+)
+
+//NewMetrics registers subscriber and producer metrics on registerer.
+//Metrics already registered, e.g. by another NewMetrics call, are reused.
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
+	subscription := []string{"subject", "queue"}
+
+	return &Metrics{
+		Received: register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grok_nats_messages_received_total",
+			Help: "Messages received by subscribers.",
+		}, subscription)).(*prometheus.CounterVec),
+		Handled: register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grok_nats_messages_handled_total",
+			Help: "Messages handled and acknowledged.",
+		}, subscription)).(*prometheus.CounterVec),
+		Failed: register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grok_nats_messages_failed_total",
+			Help: "Messages whose handler returned an error or panicked.",
+		}, subscription)).(*prometheus.CounterVec),
+		Unacked: register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grok_nats_messages_unacked_total",
+			Help: "Messages left unacknowledged, redelivered by the server.",
+		}, subscription)).(*prometheus.CounterVec),
+		DecodeErrors: register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grok_nats_decode_errors_total",
+			Help: "Messages that couldn't be decoded.",
+		}, subscription)).(*prometheus.CounterVec),
+		HandlerLatency: register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grok_nats_handler_duration_seconds",
+			Help:    "Message handler latency.",
+			Buckets: prometheus.DefBuckets,
+		}, subscription)).(*prometheus.HistogramVec),
+		Published: register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grok_nats_messages_published_total",
+			Help: "Messages published by producers.",
+		}, []string{"subject"})).(*prometheus.CounterVec),
+		PublishFailures: register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grok_nats_publish_failures_total",
+			Help: "Messages that couldn't be published.",
+		}, []string{"subject"})).(*prometheus.CounterVec),
+	}
+}
+
+//DefaultMetrics are registered on prometheus.DefaultRegisterer, used unless WithMetrics is called.
+func DefaultMetrics() *Metrics {
+	defaultMetricsOnce.Do(func() {
+		defaultMetrics = NewMetrics(prometheus.DefaultRegisterer)
+	})
+
+	return defaultMetrics
+}
+
+func register(registerer prometheus.Registerer, collector prometheus.Collector) prometheus.Collector {
+	if err := registerer.Register(collector); err != nil {
+		if registered, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return registered.ExistingCollector
+		}
+
+		panic(err)
+	}
+
+	return collector
+}
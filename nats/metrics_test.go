@@ -0,0 +1,98 @@
+package nats_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/lifecycle"
+	gnats "github.com/getmilly/grok/nats"
+	"github.com/nats-io/go-nats-streaming"
+	"github.com/nats-io/go-nats-streaming/pb"
+)
+
+type fakeConn struct {
+	stan.Conn
+	publishErr error
+	handler    stan.MsgHandler
+	subscribed chan struct{}
+}
+
+type fakeSubscription struct {
+	stan.Subscription
+}
+
+func (conn *fakeConn) Publish(subject string, data []byte) error {
+	return conn.publishErr
+}
+
+func (conn *fakeConn) QueueSubscribe(subject, queue string, cb stan.MsgHandler, opts ...stan.SubscriptionOption) (stan.Subscription, error) {
+	conn.handler = cb
+	close(conn.subscribed)
+	return &fakeSubscription{}, nil
+}
+
+func (subscription *fakeSubscription) Unsubscribe() error {
+	return nil
+}
+
+func TestProducer_RecordsPublishes(t *testing.T) {
+	metrics := gnats.NewMetrics(prometheus.NewRegistry())
+	conn := &fakeConn{}
+	producer := gnats.NewProducer(conn).WithMetrics(metrics)
+
+	message, _ := gnats.NewMessage(Testing{Value: 1})
+
+	assert.NoError(t, producer.Publish("orders", message))
+
+	conn.publishErr = errors.New("connection closed")
+	assert.Error(t, producer.Publish("orders", message))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.Published.WithLabelValues("orders")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.PublishFailures.WithLabelValues("orders")))
+}
+
+func TestSubscriber_RecordsFailures(t *testing.T) {
+	metrics := gnats.NewMetrics(prometheus.NewRegistry())
+	conn := &fakeConn{subscribed: make(chan struct{})}
+	manager := lifecycle.New(lifecycle.Options{})
+
+	go gnats.NewSubscriber(conn).
+		WithSubject("orders").
+		WithQueue("billing").
+		WithMessageType(reflect.TypeOf(Testing{})).
+		WithLifecycle(manager).
+		WithMetrics(metrics).
+		WithHandler(func(m interface{}) error {
+			return errors.New("declined")
+		}).
+		Run()
+
+	select {
+	case <-conn.subscribed:
+	case <-time.After(time.Second):
+		t.Fatal("not subscribed")
+	}
+
+	message, _ := gnats.NewMessage(Testing{Value: 1})
+	data, _ := json.Marshal(message)
+
+	conn.handler(&stan.Msg{})
+	conn.handler(&stan.Msg{MsgProto: pb.MsgProto{Data: data}})
+
+	manager.Shutdown()
+
+	labels := []string{"orders", "billing"}
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.Received.WithLabelValues(labels...)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.DecodeErrors.WithLabelValues(labels...)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.Failed.WithLabelValues(labels...)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.Unacked.WithLabelValues(labels...)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.Handled.WithLabelValues(labels...)))
+}
@@ -1,28 +1,84 @@
 package nats
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/nats-io/go-nats-streaming"
+
+	"github.com/getmilly/grok/logging"
+	"github.com/getmilly/grok/tracing"
 )
 
 //Producer wraps the publish of messages to NATS.
 type Producer struct {
-	conn stan.Conn
+	conn    stan.Conn
+	metrics *Metrics
+	tracer  *tracing.Tracer
 }
 
 //NewProducer creates a new producer.
 func NewProducer(conn stan.Conn) *Producer {
-	return &Producer{conn}
+	return &Producer{conn: conn, metrics: DefaultMetrics()}
+}
+
+//WithMetrics records metrics on the given collectors instead of DefaultMetrics.
+func (producer *Producer) WithMetrics(metrics *Metrics) *Producer {
+	producer.metrics = metrics
+	return producer
+}
+
+//WithTracer traces publishes with tracer instead of tracing.Default().
+func (producer *Producer) WithTracer(tracer *tracing.Tracer) *Producer {
+	producer.tracer = tracer
+	return producer
 }
 
 //Publish sends a message to a subject.
 func (producer *Producer) Publish(subject string, message *Message) error {
+	return producer.PublishContext(context.Background(), subject, message)
+}
+
+//PublishContext sends a message to a subject in a producer span child of the span in ctx.
+//The trace context and the request ID of the ctx logger are propagated in message.Metadata.
+func (producer *Producer) PublishContext(ctx context.Context, subject string, message *Message) error {
+	ctx, span := tracerOrDefault(producer.tracer).Start(ctx, "publish "+subject, tracing.SpanKindProducer)
+	defer span.End()
+
+	span.SetAttribute("messaging.system", "nats")
+	span.SetAttribute("messaging.destination", subject)
+	span.SetAttribute("messaging.message_id", message.ID)
+
+	if message.Metadata == nil {
+		message.Metadata = make(map[string]interface{})
+	}
+
+	tracing.Inject(ctx, tracing.MapCarrier(message.Metadata))
+
+	if id, ok := logging.FromContext(ctx).Field(logging.RequestIDField).(string); ok && id != "" {
+		message.Metadata[logging.RequestIDField] = id
+	}
+
 	m, err := json.Marshal(message)
 
+	if err == nil {
+		err = producer.conn.Publish(subject, m)
+	}
+
 	if err != nil {
+		span.RecordError(err)
+		producer.metrics.PublishFailures.WithLabelValues(subject).Inc()
 		return err
 	}
 
-	return producer.conn.Publish(subject, m)
+	producer.metrics.Published.WithLabelValues(subject).Inc()
+	return nil
+}
+
+func tracerOrDefault(tracer *tracing.Tracer) *tracing.Tracer {
+	if tracer == nil {
+		return tracing.Default()
+	}
+
+	return tracer
 }
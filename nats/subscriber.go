@@ -1,16 +1,19 @@
 package nats
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"os"
-	"os/signal"
+	"fmt"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/nats-io/go-nats-streaming"
 
+	"github.com/getmilly/grok/lifecycle"
 	"github.com/getmilly/grok/logging"
+	"github.com/getmilly/grok/tracing"
 )
 
 //Subscriber is an asynchronous subscriber.
@@ -19,15 +22,25 @@ type Subscriber struct {
 	subject      string
 	queue        string
 	messageType  reflect.Type
+	mu           sync.RWMutex
 	subscription stan.Subscription
 	handler      MessageHandler
-	shutdown     chan os.Signal
-	done         chan bool
+	ctxHandler   ContextMessageHandler
+	lifecycle    *lifecycle.Manager
+	shared       bool
+	inflight     sync.WaitGroup
+	metrics      *Metrics
+	tracer       *tracing.Tracer
+	logger       *logging.Logger
 }
 
 //MessageHandler handles incoming subject messages.
 type MessageHandler func(interface{}) error
 
+//ContextMessageHandler handles incoming subject messages with a context carrying the consumer span
+//and a logger with the message correlation IDs, see logging.FromContext.
+type ContextMessageHandler func(context.Context, interface{}) error
+
 //NewSubscriber creates a new subscriber.
 func NewSubscriber(conn stan.Conn) *Subscriber {
 	return &Subscriber{
@@ -47,6 +60,12 @@ func (subscriber *Subscriber) WithHandler(handler MessageHandler) *Subscriber {
 	return subscriber
 }
 
+//WithContextHandler sets a subscription handler receiving the message context.
+func (subscriber *Subscriber) WithContextHandler(handler ContextMessageHandler) *Subscriber {
+	subscriber.ctxHandler = handler
+	return subscriber
+}
+
 //WithQueue sets the subscription queue.
 func (subscriber *Subscriber) WithQueue(queue string) *Subscriber {
 	subscriber.queue = queue
@@ -59,12 +78,42 @@ func (subscriber *Subscriber) WithMessageType(t reflect.Type) *Subscriber {
 	return subscriber
 }
 
+//WithLifecycle shares a lifecycle manager, e.g. api.Server.Lifecycle.
+//The connection isn't closed by the subscriber when the manager is shared.
+func (subscriber *Subscriber) WithLifecycle(manager *lifecycle.Manager) *Subscriber {
+	subscriber.lifecycle = manager
+	subscriber.shared = true
+	return subscriber
+}
+
+//WithMetrics records metrics on the given collectors instead of DefaultMetrics.
+func (subscriber *Subscriber) WithMetrics(metrics *Metrics) *Subscriber {
+	subscriber.metrics = metrics
+	return subscriber
+}
+
+//WithTracer traces messages with tracer instead of tracing.Default().
+func (subscriber *Subscriber) WithTracer(tracer *tracing.Tracer) *Subscriber {
+	subscriber.tracer = tracer
+	return subscriber
+}
+
+//WithLogger logs messages with logger instead of logging.Default().
+func (subscriber *Subscriber) WithLogger(logger *logging.Logger) *Subscriber {
+	subscriber.logger = logger
+	return subscriber
+}
+
 //Run starts the subject subscription.
 func (subscriber *Subscriber) Run() error {
 	if err := subscriber.validate(); err != nil {
 		return err
 	}
 
+	if subscriber.metrics == nil {
+		subscriber.metrics = DefaultMetrics()
+	}
+
 	subscription, err := subscriber.conn.QueueSubscribe(
 		subscriber.subject,
 		subscriber.queue,
@@ -78,43 +127,117 @@ func (subscriber *Subscriber) Run() error {
 		return err
 	}
 
+	subscriber.mu.Lock()
 	subscriber.subscription = subscription
+	subscriber.mu.Unlock()
 
 	subscriber.handleShutdown()
 
-	<-subscriber.done
-
-	return nil
+	return subscriber.lifecycle.Wait()
 }
 
 func (subscriber *Subscriber) messageHandler(msg *stan.Msg) {
+	subscriber.inflight.Add(1)
+	defer subscriber.inflight.Done()
+
+	labels := []string{subscriber.subject, subscriber.queue}
+	metrics := subscriber.metrics
+
+	metrics.Received.WithLabelValues(labels...).Inc()
+
+	acked := false
+
+	defer func() {
+		if !acked {
+			metrics.Unacked.WithLabelValues(labels...).Inc()
+		}
+	}()
+
 	message := &Message{}
 	v := reflect.New(subscriber.messageType).Interface()
 
 	if err := json.Unmarshal(msg.Data, &message); err != nil {
+		metrics.DecodeErrors.WithLabelValues(labels...).Inc()
 		logging.LogWith(err).Warn("payload wasn't type of `Message`")
 		return
 	}
 
 	if err := json.Unmarshal(message.Data, v); err != nil {
+		metrics.DecodeErrors.WithLabelValues(labels...).Inc()
 		logging.LogWith(err).Warn("message data wasn't type of `%s`", subscriber.messageType.Name())
 		return
 	}
 
+	ctx := tracing.Extract(context.Background(), tracing.MapCarrier(message.Metadata))
+	ctx, span := tracerOrDefault(subscriber.tracer).Start(ctx, "process "+subscriber.subject, tracing.SpanKindConsumer)
+	defer span.End()
+
+	span.SetAttribute("messaging.system", "nats")
+	span.SetAttribute("messaging.destination", subscriber.subject)
+	span.SetAttribute("messaging.consumer_group", subscriber.queue)
+	span.SetAttribute("messaging.message_id", message.ID)
+
+	ctx = subscriber.logContext(ctx, message, span)
+	log := logging.FromContext(ctx)
+
+	log.WithPayload(v).Info("incoming message")
+
+	started := time.Now()
+	err := subscriber.handle(ctx, v)
+	metrics.HandlerLatency.WithLabelValues(labels...).Observe(time.Since(started).Seconds())
+
+	if err != nil {
+		span.RecordError(err)
+		metrics.Failed.WithLabelValues(labels...).Inc()
+		log.With(err).Error("handle error")
+		return
+	}
+
+	if err := msg.Ack(); err != nil {
+		log.With(err).Error("ack error")
+		return
+	}
+
+	acked = true
+	metrics.Handled.WithLabelValues(labels...).Inc()
+}
+
+//logContext attaches the message, trace and producer request IDs to the ctx logger.
+func (subscriber *Subscriber) logContext(ctx context.Context, message *Message, span *tracing.Span) context.Context {
+	sc := span.SpanContext()
+
+	fields := map[string]interface{}{
+		logging.MessageIDField: message.ID,
+		logging.TraceIDField:   sc.TraceID.String(),
+		logging.SpanIDField:    sc.SpanID.String(),
+		"nats_subject":         subscriber.subject,
+		"queue":                subscriber.queue,
+	}
+
+	if id, ok := message.Metadata[logging.RequestIDField].(string); ok {
+		fields[logging.RequestIDField] = id
+	}
+
+	if subscriber.logger != nil {
+		return logging.WithContext(ctx, subscriber.logger.WithFields(fields))
+	}
+
+	return logging.ContextWithFields(ctx, fields)
+}
+
+func (subscriber *Subscriber) handle(ctx context.Context, v interface{}) (err error) {
 	defer func() {
-		if err := recover(); err != nil {
-			logging.LogWith(err).Error("handler panics")
+		if recovered := recover(); recovered != nil {
+			logging.FromContext(ctx).With(recovered).Error("handler panics")
+			err = fmt.Errorf("handler panics: %v", recovered)
 		}
 	}()
 
-	logging.LogWith(v).Info("incoming message")
-
-	if err := subscriber.handler(v); err != nil {
-		logging.LogWith(err).Error("handle error")
-		return
+	if subscriber.ctxHandler != nil {
+		return subscriber.ctxHandler(ctx, v)
 	}
 
-	msg.Ack()
+	return subscriber.handler(v)
 }
 
 func (subscriber *Subscriber) validate() error {
@@ -126,7 +249,7 @@ func (subscriber *Subscriber) validate() error {
 		return errors.New("`queue` must be set")
 	}
 
-	if subscriber.handler == nil {
+	if subscriber.handler == nil && subscriber.ctxHandler == nil {
 		return errors.New("`handler` must be set")
 	}
 
@@ -138,20 +261,51 @@ func (subscriber *Subscriber) validate() error {
 }
 
 func (subscriber *Subscriber) handleShutdown() {
-	subscriber.done = make(chan bool)
-	subscriber.shutdown = make(chan os.Signal)
-	signal.Notify(subscriber.shutdown, os.Interrupt)
+	if subscriber.lifecycle == nil {
+		subscriber.lifecycle = lifecycle.New(lifecycle.Options{})
+	}
 
-	go func() {
-		sig := <-subscriber.shutdown
-		logging.LogInfo("caught sig: %+v", sig)
-		logging.LogInfo("waiting 5 seconds to finish processing")
+	subscriber.lifecycle.Register(lifecycle.StageConsumers, "subscription "+subscriber.subject, subscriber.drain)
+
+	if !subscriber.shared {
+		subscriber.lifecycle.OnShutdown("nats connection", subscriber.close)
+	}
+}
+
+func (subscriber *Subscriber) drain(ctx context.Context) error {
+	err := subscriber.current().Unsubscribe()
 
-		subscriber.subscription.Unsubscribe()
-		subscriber.conn.NatsConn().Drain()
-		subscriber.conn.NatsConn().FlushTimeout(5 * time.Second)
-		subscriber.conn.Close()
+	done := make(chan struct{})
 
-		subscriber.done <- true
+	go func() {
+		subscriber.inflight.Wait()
+		close(done)
 	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//current returns the subscription started by Run, nil before.
+func (subscriber *Subscriber) current() stan.Subscription {
+	subscriber.mu.RLock()
+	defer subscriber.mu.RUnlock()
+
+	return subscriber.subscription
+}
+
+func (subscriber *Subscriber) close(ctx context.Context) error {
+	timeout := 5 * time.Second
+
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	subscriber.conn.NatsConn().FlushTimeout(timeout)
+
+	return subscriber.conn.Close()
 }
@@ -0,0 +1,73 @@
+package nats_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/lifecycle"
+	"github.com/getmilly/grok/logging"
+	gnats "github.com/getmilly/grok/nats"
+	"github.com/getmilly/grok/tracing"
+	"github.com/nats-io/go-nats-streaming"
+	"github.com/nats-io/go-nats-streaming/pb"
+)
+
+func TestTracing_ContinuesTraceFromProducerToSubscriber(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+	tracer := tracing.NewTracer(tracing.Options{ServiceName: "orders", Exporter: exporter})
+	metrics := gnats.NewMetrics(prometheus.NewRegistry())
+
+	conn := &fakeConn{subscribed: make(chan struct{})}
+	manager := lifecycle.New(lifecycle.Options{})
+	handled := make(chan tracing.SpanContext, 1)
+
+	go gnats.NewSubscriber(conn).
+		WithSubject("orders").
+		WithQueue("billing").
+		WithMessageType(reflect.TypeOf(Testing{})).
+		WithLifecycle(manager).
+		WithMetrics(metrics).
+		WithTracer(tracer).
+		WithContextHandler(func(ctx context.Context, m interface{}) error {
+			handled <- tracing.SpanContextFromContext(ctx)
+			return context.Canceled
+		}).
+		Run()
+
+	select {
+	case <-conn.subscribed:
+	case <-time.After(time.Second):
+		t.Fatal("not subscribed")
+	}
+
+	ctx, root := tracer.Start(context.Background(), "checkout", tracing.SpanKindServer)
+	ctx = logging.ContextWithFields(ctx, map[string]interface{}{logging.RequestIDField: "request-42"})
+
+	message, _ := gnats.NewMessage(Testing{Value: 1})
+	producer := gnats.NewProducer(conn).WithMetrics(metrics).WithTracer(tracer)
+	assert.NoError(t, producer.PublishContext(ctx, "orders", message))
+	assert.NotEmpty(t, message.Metadata[tracing.TraceparentKey])
+	assert.Equal(t, "request-42", message.Metadata[logging.RequestIDField])
+
+	data, _ := json.Marshal(message)
+	conn.handler(&stan.Msg{MsgProto: pb.MsgProto{Data: data}})
+
+	manager.Shutdown()
+	root.End()
+
+	sc := <-handled
+	spans := exporter.Spans()
+
+	assert.Len(t, spans, 3)
+	assert.Equal(t, "publish orders", spans[0].Name)
+	assert.Equal(t, "process orders", spans[1].Name)
+	assert.Equal(t, root.SpanContext().TraceID, sc.TraceID)
+	assert.Equal(t, spans[0].SpanContext.SpanID, spans[1].Parent)
+	assert.Equal(t, tracing.StatusError, spans[1].Status)
+}
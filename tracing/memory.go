@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+//InMemoryExporter keeps finished spans, for tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+//NewInMemoryExporter creates an empty exporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+//Export keeps spans.
+func (exporter *InMemoryExporter) Export(ctx context.Context, spans []SpanData) error {
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+
+	exporter.spans = append(exporter.spans, spans...)
+	return nil
+}
+
+//Shutdown does nothing.
+func (exporter *InMemoryExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+//Spans returns the spans exported so far, in end order.
+func (exporter *InMemoryExporter) Spans() []SpanData {
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+
+	return append([]SpanData{}, exporter.spans...)
+}
+
+//Reset drops exported spans.
+func (exporter *InMemoryExporter) Reset() {
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+
+	exporter.spans = nil
+}
@@ -0,0 +1,302 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getmilly/grok/logging"
+)
+
+//OTLPOptions configures an OTLPExporter.
+type OTLPOptions struct {
+	//Endpoint is the collector base URL, e.g. `http://otel-collector:4318`.
+	Endpoint string
+	Headers  map[string]string
+	//BatchSize spans are sent at once, 512 when zero.
+	BatchSize int
+	//FlushInterval sends incomplete batches, 5 seconds when zero.
+	FlushInterval time.Duration
+	//QueueSize spans are buffered, newer spans are dropped when full, 2048 when zero.
+	QueueSize int
+	Client    *http.Client
+}
+
+//OTLPExporter sends spans in batches with OTLP over HTTP and JSON.
+type OTLPExporter struct {
+	dropped uint64
+	options OTLPOptions
+	queue   chan SpanData
+	flush   chan chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+//NewOTLPExporter starts a background sender, call Shutdown to flush it.
+func NewOTLPExporter(options OTLPOptions) *OTLPExporter {
+	if options.BatchSize <= 0 {
+		options.BatchSize = 512
+	}
+
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = 5 * time.Second
+	}
+
+	if options.QueueSize <= 0 {
+		options.QueueSize = 2048
+	}
+
+	if options.Client == nil {
+		options.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	exporter := &OTLPExporter{
+		options: options,
+		queue:   make(chan SpanData, options.QueueSize),
+		flush:   make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go exporter.run()
+
+	return exporter
+}
+
+//Export queues spans, dropping them when the queue is full, see Dropped.
+func (exporter *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	for _, span := range spans {
+		select {
+		case exporter.queue <- span:
+		case <-exporter.done:
+			return nil
+		default:
+			atomic.AddUint64(&exporter.dropped, 1)
+		}
+	}
+
+	return nil
+}
+
+//Dropped returns the number of spans dropped because the queue was full.
+func (exporter *OTLPExporter) Dropped() uint64 {
+	return atomic.LoadUint64(&exporter.dropped)
+}
+
+//Shutdown sends queued spans and stops the exporter.
+func (exporter *OTLPExporter) Shutdown(ctx context.Context) error {
+	flushed := make(chan struct{})
+
+	select {
+	case exporter.flush <- flushed:
+	case <-exporter.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (exporter *OTLPExporter) run() {
+	ticker := time.NewTicker(exporter.options.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []SpanData
+
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := exporter.send(batch); err != nil {
+			logging.LogWith(err).Error("otlp export error")
+		}
+
+		batch = nil
+	}
+
+	for {
+		select {
+		case span := <-exporter.queue:
+			batch = append(batch, span)
+
+			if len(batch) >= exporter.options.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case flushed := <-exporter.flush:
+			exporter.once.Do(func() {
+				close(exporter.done)
+			})
+
+			for len(exporter.queue) > 0 {
+				batch = append(batch, <-exporter.queue)
+			}
+
+			send()
+			close(flushed)
+			return
+		}
+	}
+}
+
+func (exporter *OTLPExporter) send(spans []SpanData) error {
+	body, err := json.Marshal(encodeOTLP(spans))
+
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(exporter.options.Endpoint, "/") + "/v1/traces"
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for key, value := range exporter.options.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := exporter.options.Client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("otlp collector answered %s", resp.Status)
+	}
+
+	return nil
+}
+
+func encodeOTLP(spans []SpanData) otlpRequest {
+	services := make(map[string][]otlpSpan)
+	var order []string
+
+	for _, span := range spans {
+		if _, ok := services[span.ServiceName]; !ok {
+			order = append(order, span.ServiceName)
+		}
+
+		encoded := otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Attributes:        encodeAttributes(span.Attributes),
+			Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+		}
+
+		if span.Parent.IsValid() {
+			encoded.ParentSpanID = span.Parent.String()
+		}
+
+		services[span.ServiceName] = append(services[span.ServiceName], encoded)
+	}
+
+	request := otlpRequest{}
+
+	for _, service := range order {
+		request.ResourceSpans = append(request.ResourceSpans, otlpResourceSpans{
+			Resource: otlpResource{
+				Attributes: encodeAttributes(map[string]interface{}{"service.name": service}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/getmilly/grok/tracing"},
+				Spans: services[service],
+			}},
+		})
+	}
+
+	return request
+}
+
+func encodeAttributes(attributes map[string]interface{}) []otlpAttribute {
+	var encoded []otlpAttribute
+
+	for key, value := range attributes {
+		var v map[string]interface{}
+
+		switch value := value.(type) {
+		case bool:
+			v = map[string]interface{}{"boolValue": value}
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(value)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+		case float64:
+			v = map[string]interface{}{"doubleValue": value}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+		}
+
+		encoded = append(encoded, otlpAttribute{Key: key, Value: v})
+	}
+
+	return encoded
+}
@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//TraceparentKey is the W3C trace context header and metadata key.
+const TraceparentKey = "traceparent"
+
+var (
+	//ErrInvalidTraceparent is returned for malformed traceparent values.
+	ErrInvalidTraceparent = errors.New("invalid traceparent")
+)
+
+//Carrier reads and writes propagated values, e.g. HTTP headers or message metadata.
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+//HeaderCarrier propagates through HTTP headers.
+type HeaderCarrier http.Header
+
+//MapCarrier propagates through string values of a map, e.g. nats.Message.Metadata.
+type MapCarrier map[string]interface{}
+
+type contextKey int
+
+const (
+	spanKey contextKey = iota
+	remoteKey
+)
+
+//Get returns the header value.
+func (carrier HeaderCarrier) Get(key string) string {
+	return http.Header(carrier).Get(key)
+}
+
+//Set sets the header value.
+func (carrier HeaderCarrier) Set(key, value string) {
+	http.Header(carrier).Set(key, value)
+}
+
+//Get returns the value if it's a string.
+func (carrier MapCarrier) Get(key string) string {
+	value, _ := carrier[key].(string)
+	return value
+}
+
+//Set sets the value.
+func (carrier MapCarrier) Set(key, value string) {
+	carrier[key] = value
+}
+
+//Traceparent formats the span context as a W3C traceparent.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+//ParseTraceparent parses a W3C traceparent, e.g. `00-<trace id>-<span id>-01`.
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	var sc SpanContext
+
+	if err := decodeHex(sc.TraceID[:], parts[1]); err != nil {
+		return SpanContext{}, err
+	}
+
+	if err := decodeHex(sc.SpanID[:], parts[2]); err != nil {
+		return SpanContext{}, err
+	}
+
+	flags := make([]byte, 1)
+
+	if err := decodeHex(flags, parts[3]); err != nil {
+		return SpanContext{}, err
+	}
+
+	sc.Sampled = flags[0]&1 == 1
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	return sc, nil
+}
+
+//Inject writes the span context of ctx into carrier.
+func Inject(ctx context.Context, carrier Carrier) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		carrier.Set(TraceparentKey, sc.Traceparent())
+	}
+}
+
+//Extract returns ctx with the remote span context found in carrier, if any.
+func Extract(ctx context.Context, carrier Carrier) context.Context {
+	sc, err := ParseTraceparent(carrier.Get(TraceparentKey))
+
+	if err != nil {
+		return ctx
+	}
+
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+//ContextWithSpan returns ctx carrying span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey, span)
+}
+
+//SpanFromContext returns the span in ctx, nil if none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+//ContextWithRemoteSpanContext returns ctx carrying a parent extracted from another process.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey, sc)
+}
+
+//SpanContextFromContext returns the span context of the span in ctx, or the remote one.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil && span.SpanContext().IsValid() {
+		return span.SpanContext()
+	}
+
+	sc, _ := ctx.Value(remoteKey).(SpanContext)
+	return sc
+}
+
+func decodeHex(dst []byte, value string) error {
+	if len(value) != hex.EncodedLen(len(dst)) || strings.ToLower(value) != value {
+		return ErrInvalidTraceparent
+	}
+
+	if _, err := hex.Decode(dst, []byte(value)); err != nil {
+		return ErrInvalidTraceparent
+	}
+
+	return nil
+}
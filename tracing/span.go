@@ -0,0 +1,191 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+//SpanKind follows the OTLP span kinds.
+type SpanKind int
+
+//Span kinds.
+const (
+	SpanKindInternal SpanKind = iota + 1
+	SpanKindServer
+	SpanKindClient
+	SpanKindProducer
+	SpanKindConsumer
+)
+
+//StatusCode follows the OTLP status codes.
+type StatusCode int
+
+//Span status codes.
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+//TraceID identifies a trace.
+type TraceID [16]byte
+
+//SpanID identifies a span in a trace.
+type SpanID [8]byte
+
+//SpanContext is the part of a span propagated across processes.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+//SpanData is a finished span handed to exporters.
+type SpanData struct {
+	ServiceName   string
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	Parent        SpanID
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    map[string]interface{}
+	Status        StatusCode
+	StatusMessage string
+}
+
+//Span is an operation in a trace. A zero or nil Span is a valid no-op span.
+type Span struct {
+	recording *recording
+}
+
+type recording struct {
+	mu       sync.Mutex
+	data     SpanData
+	tracer   *Tracer
+	exported bool
+	ended    bool
+}
+
+//String returns the hex encoded id.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+//IsValid reports whether id isn't all zeros.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+//String returns the hex encoded id.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+//IsValid reports whether id isn't all zeros.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+//IsValid reports whether both ids are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+//SpanContext returns the propagated identity of the span.
+func (span *Span) SpanContext() SpanContext {
+	if span == nil || span.recording == nil {
+		return SpanContext{}
+	}
+
+	return span.recording.data.SpanContext
+}
+
+//IsRecording reports whether the span will be exported on End.
+func (span *Span) IsRecording() bool {
+	return span != nil && span.recording != nil && span.recording.exported
+}
+
+//SetName replaces the span name, e.g. once the route is known.
+func (span *Span) SetName(name string) {
+	span.update(func(data *SpanData) {
+		data.Name = name
+	})
+}
+
+//SetAttribute sets a string, bool, integer or float attribute.
+func (span *Span) SetAttribute(key string, value interface{}) {
+	span.update(func(data *SpanData) {
+		data.Attributes[key] = value
+	})
+}
+
+//SetStatus sets the span status, message is kept only for StatusError.
+func (span *Span) SetStatus(code StatusCode, message string) {
+	span.update(func(data *SpanData) {
+		data.Status = code
+		data.StatusMessage = ""
+
+		if code == StatusError {
+			data.StatusMessage = message
+		}
+	})
+}
+
+//RecordError marks the span as failed by err.
+func (span *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	span.SetStatus(StatusError, err.Error())
+}
+
+//End finishes the span and exports it.
+func (span *Span) End() {
+	if !span.IsRecording() {
+		return
+	}
+
+	r := span.recording
+	r.mu.Lock()
+
+	if r.ended {
+		r.mu.Unlock()
+		return
+	}
+
+	r.ended = true
+	r.data.EndTime = time.Now()
+	data := r.data
+	r.mu.Unlock()
+
+	r.tracer.export(data)
+}
+
+func (span *Span) update(fn func(data *SpanData)) {
+	if !span.IsRecording() {
+		return
+	}
+
+	span.recording.mu.Lock()
+	defer span.recording.mu.Unlock()
+
+	if !span.recording.ended {
+		fn(&span.recording.data)
+	}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
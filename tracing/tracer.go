@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/getmilly/grok/logging"
+)
+
+//Exporter sends finished spans to a backend.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+//Options configures a Tracer.
+type Options struct {
+	ServiceName string
+	//Exporter receives finished spans, spans are only propagated when nil.
+	Exporter Exporter
+	//SampleRatio of new traces recorded, from 0 to 1, 1 when zero.
+	//Traces started by a remote parent follow its sampled flag.
+	SampleRatio float64
+}
+
+//Tracer starts spans and exports them when they end.
+type Tracer struct {
+	options Options
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultTracer = NewTracer(Options{})
+)
+
+//NewTracer creates a tracer.
+func NewTracer(options Options) *Tracer {
+	if options.SampleRatio <= 0 || options.SampleRatio > 1 {
+		options.SampleRatio = 1
+	}
+
+	return &Tracer{options: options}
+}
+
+//Default returns the tracer set by SetDefault, which only propagates context until then.
+func Default() *Tracer {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+
+	return defaultTracer
+}
+
+//SetDefault replaces the tracer used by Start.
+func SetDefault(tracer *Tracer) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	defaultTracer = tracer
+}
+
+//Start starts a span with the default tracer, see Tracer.Start.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	return Default().Start(ctx, name, kind)
+}
+
+//Start starts a span child of the span or remote span context in ctx.
+//The sampled flag is propagated even without an exporter, so downstream services keep sampling.
+//End must be called on the returned span.
+func (tracer *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	sc := SpanContext{
+		TraceID: parent.TraceID,
+		SpanID:  newSpanID(),
+		Sampled: parent.Sampled,
+	}
+
+	if !parent.IsValid() {
+		sc.TraceID = newTraceID()
+		sc.Sampled = tracer.sample(sc.TraceID)
+	}
+
+	span := &Span{recording: &recording{
+		tracer:   tracer,
+		exported: sc.Sampled && tracer.options.Exporter != nil,
+		data: SpanData{
+			ServiceName: tracer.options.ServiceName,
+			Name:        name,
+			Kind:        kind,
+			SpanContext: sc,
+			Parent:      parent.SpanID,
+			StartTime:   time.Now(),
+			Attributes:  make(map[string]interface{}),
+		},
+	}}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+//Shutdown flushes and stops the exporter.
+func (tracer *Tracer) Shutdown(ctx context.Context) error {
+	if tracer.options.Exporter == nil {
+		return nil
+	}
+
+	return tracer.options.Exporter.Shutdown(ctx)
+}
+
+func (tracer *Tracer) sample(id TraceID) bool {
+	if tracer.options.SampleRatio >= 1 {
+		return true
+	}
+
+	bound := uint64(tracer.options.SampleRatio * math.MaxUint64)
+
+	return binary.BigEndian.Uint64(id[8:]) < bound
+}
+
+func (tracer *Tracer) export(data SpanData) {
+	if err := tracer.options.Exporter.Export(context.Background(), []SpanData{data}); err != nil {
+		logging.LogWith(err).Error("span export error")
+	}
+}
@@ -0,0 +1,146 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/getmilly/grok/tracing"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := tracing.ParseTraceparent(traceparent)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, traceparent, sc.Traceparent())
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		_, err := tracing.ParseTraceparent(invalid)
+		assert.Equal(t, tracing.ErrInvalidTraceparent, err, invalid)
+	}
+}
+
+func TestTracer_ContinuesRemoteTrace(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+	tracer := tracing.NewTracer(tracing.Options{ServiceName: "orders", Exporter: exporter})
+
+	header := http.Header{}
+	header.Set(tracing.TraceparentKey, traceparent)
+
+	ctx := tracing.Extract(context.Background(), tracing.HeaderCarrier(header))
+	ctx, parent := tracer.Start(ctx, "GET /orders", tracing.SpanKindServer)
+	_, child := tracer.Start(ctx, "find order", tracing.SpanKindInternal)
+
+	child.SetAttribute("db.system", "mongodb")
+	child.End()
+	parent.End()
+
+	spans := exporter.Spans()
+
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "find order", spans[0].Name)
+	assert.Equal(t, parent.SpanContext().SpanID, spans[0].Parent)
+	assert.Equal(t, "mongodb", spans[0].Attributes["db.system"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[1].SpanContext.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[1].Parent.String())
+
+	injected := http.Header{}
+	tracing.Inject(ctx, tracing.HeaderCarrier(injected))
+	assert.Equal(t, parent.SpanContext().Traceparent(), injected.Get(tracing.TraceparentKey))
+}
+
+func TestTracer_WithoutExporterOnlyPropagates(t *testing.T) {
+	ctx, span := tracing.NewTracer(tracing.Options{}).Start(context.Background(), "noop", tracing.SpanKindInternal)
+	defer span.End()
+
+	assert.False(t, span.IsRecording())
+	assert.True(t, tracing.SpanContextFromContext(ctx).IsValid())
+}
+
+func TestTracer_WithoutExporterKeepsRemoteSampling(t *testing.T) {
+	parent, err := tracing.ParseTraceparent(traceparent)
+	assert.NoError(t, err)
+
+	ctx := tracing.ContextWithRemoteSpanContext(context.Background(), parent)
+	ctx, span := tracing.NewTracer(tracing.Options{}).Start(ctx, "proxy", tracing.SpanKindServer)
+	defer span.End()
+
+	assert.False(t, span.IsRecording())
+	assert.True(t, span.SpanContext().Sampled)
+
+	injected := http.Header{}
+	tracing.Inject(ctx, tracing.HeaderCarrier(injected))
+	assert.True(t, strings.HasSuffix(injected.Get(tracing.TraceparentKey), "-01"))
+}
+
+func TestOTLPExporter_CountsDroppedSpans(t *testing.T) {
+	release := make(chan struct{})
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer collector.Close()
+
+	exporter := tracing.NewOTLPExporter(tracing.OTLPOptions{Endpoint: collector.URL, BatchSize: 1, QueueSize: 1})
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, exporter.Export(context.Background(), []tracing.SpanData{{Name: "checkout"}}))
+	}
+
+	assert.True(t, exporter.Dropped() > 0)
+
+	close(release)
+	assert.NoError(t, exporter.Shutdown(context.Background()))
+}
+
+func TestOTLPExporter_FlushesOnShutdown(t *testing.T) {
+	received := make(chan map[string]interface{}, 1)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+
+		body, _ := ioutil.ReadAll(r.Body)
+		payload := make(map[string]interface{})
+		json.Unmarshal(body, &payload)
+
+		received <- payload
+	}))
+	defer collector.Close()
+
+	tracer := tracing.NewTracer(tracing.Options{
+		ServiceName: "orders",
+		Exporter:    tracing.NewOTLPExporter(tracing.OTLPOptions{Endpoint: collector.URL}),
+	})
+
+	_, span := tracer.Start(context.Background(), "checkout", tracing.SpanKindServer)
+	span.End()
+
+	assert.NoError(t, tracer.Shutdown(context.Background()))
+
+	payload := <-received
+	resource := payload["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	scope := resource["scopeSpans"].([]interface{})[0].(map[string]interface{})
+	encoded := scope["spans"].([]interface{})[0].(map[string]interface{})
+
+	assert.Equal(t, "checkout", encoded["name"])
+	assert.Equal(t, span.SpanContext().TraceID.String(), encoded["traceId"])
+	assert.Equal(t, float64(tracing.SpanKindServer), encoded["kind"])
+}